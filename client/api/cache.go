@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"context"
+	"sync"
+
+	"github.com/linode/linodego"
+)
+
+// imageCache caches the images of the Linode API. Images are looked up for
+// every listed instance, most of them sharing the same image.
+type imageCache struct {
+	LinodeAPI
+
+	mu     sync.Mutex
+	images map[string]*linodego.Image
+}
+
+func newImageCache(a LinodeAPI) *imageCache {
+	return &imageCache{
+		LinodeAPI: a,
+		images:    map[string]*linodego.Image{},
+	}
+}
+
+// GetImage returns the image from the cache, or from the Linode API when it
+// is not cached yet. Errors are not cached.
+func (c *imageCache) GetImage(ctx context.Context, ID string) (*linodego.Image, error) {
+	c.mu.Lock()
+	image, ok := c.images[ID]
+	c.mu.Unlock()
+	if ok {
+		return image, nil
+	}
+
+	image, err := c.LinodeAPI.GetImage(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.images[ID] = image
+	c.mu.Unlock()
+
+	return image, nil
+}
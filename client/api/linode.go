@@ -13,13 +13,45 @@ import (
 )
 
 type LinodeAPI interface {
+	AttachVolume(context.Context, int, *linodego.VolumeAttachOptions) (*linodego.Volume, error)
+	BootInstance(context.Context, int, int) error
+	CreateFirewall(context.Context, linodego.FirewallCreateOptions) (*linodego.Firewall, error)
 	CreateInstance(context.Context, linodego.InstanceCreateOptions) (*linodego.Instance, error)
+	CreateInstanceConfig(context.Context, int, linodego.InstanceConfigCreateOptions) (*linodego.InstanceConfig, error)
+	CreateInstanceDisk(context.Context, int, linodego.InstanceDiskCreateOptions) (*linodego.InstanceDisk, error)
+	CreatePlacementGroup(context.Context, linodego.PlacementGroupCreateOptions) (*linodego.PlacementGroup, error)
+	CreateVolume(context.Context, linodego.VolumeCreateOptions) (*linodego.Volume, error)
+	DeleteFirewall(context.Context, int) error
 	DeleteInstance(context.Context, int) error
+	DeletePlacementGroup(context.Context, int) error
+	DeleteVolume(context.Context, int) error
+	GetFirewall(context.Context, int) (*linodego.Firewall, error)
+	GetImage(context.Context, string) (*linodego.Image, error)
 	GetInstance(context.Context, int) (*linodego.Instance, error)
+	GetInstanceDisk(context.Context, int, int) (*linodego.InstanceDisk, error)
+	GetInstanceIPAddresses(context.Context, int) (*linodego.InstanceIPAddressResponse, error)
+	GetPlacementGroup(context.Context, int) (*linodego.PlacementGroup, error)
+	GetRegion(context.Context, string) (*linodego.Region, error)
+	GetRegionAvailability(context.Context, string) ([]linodego.RegionAvailability, error)
+	GetStackscript(context.Context, int) (*linodego.Stackscript, error)
+	GetType(context.Context, string) (*linodego.LinodeType, error)
+	GetVolume(context.Context, int) (*linodego.Volume, error)
+	GetVPC(context.Context, int) (*linodego.VPC, error)
+	ListEvents(context.Context, *linodego.ListOptions) ([]linodego.Event, error)
+	ListFirewalls(context.Context, *linodego.ListOptions) ([]linodego.Firewall, error)
 	ListInstances(context.Context, *linodego.ListOptions) ([]linodego.Instance, error)
+	ListInstanceVolumes(context.Context, int, *linodego.ListOptions) ([]linodego.Volume, error)
+	ListPlacementGroups(context.Context, *linodego.ListOptions) ([]linodego.PlacementGroup, error)
+	ListVolumes(context.Context, *linodego.ListOptions) ([]linodego.Volume, error)
+	ListVPCs(context.Context, *linodego.ListOptions) ([]linodego.VPC, error)
+	ShutdownInstance(context.Context, int) error
+	UpdateInstance(context.Context, int, linodego.InstanceUpdateOptions) (*linodego.Instance, error)
+	UpdateVolume(context.Context, int, linodego.VolumeUpdateOptions) (*linodego.Volume, error)
 }
 
-func New(cfg *config.Config) (LinodeAPI, error) {
+// New returns a Linode API client. ctx bounds getting the token, not the
+// requests of the client.
+func New(ctx context.Context, cfg *config.Config) (LinodeAPI, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("configuration is nil")
 	}
@@ -28,13 +60,33 @@ func New(cfg *config.Config) (LinodeAPI, error) {
 		return nil, fmt.Errorf("validating configuration: %w", err)
 	}
 
-	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token})
+	token, err := cfg.GetToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting token: %w", err)
+	}
+
+	maxAttempts := cfg.APIMaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	oauth2Linode := &http.Client{
 		Transport: &oauth2.Transport{
 			Source: tokenSource,
+			Base: &retryTransport{
+				next:        http.DefaultTransport,
+				maxAttempts: maxAttempts,
+			},
 		},
 	}
 
 	client := linodego.NewClient(oauth2Linode)
-	return &client, nil
+	// Retries are handled by retryTransport.
+	client.SetRetryCount(0)
+	if cfg.APIURL != "" {
+		client.SetBaseURL(cfg.APIURL)
+	}
+
+	return newImageCache(&client), nil
 }
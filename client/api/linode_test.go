@@ -3,8 +3,17 @@
 package api_test
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/linode/linodego"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/flatcar/garm-provider-linode/client/api"
@@ -14,6 +23,7 @@ import (
 func TestCreateAPI(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		_, err := api.New(
+			t.Context(),
 			&config.Config{
 				Token: "foo",
 			},
@@ -23,13 +33,221 @@ func TestCreateAPI(t *testing.T) {
 
 	t.Run("Failure without token", func(t *testing.T) {
 		_, err := api.New(
+			t.Context(),
 			&config.Config{},
 		)
 		require.ErrorContains(t, err, "validating configuration: token needs to be set")
 	})
 
+	t.Run("Failure with an unset token_env", func(t *testing.T) {
+		_, err := api.New(
+			t.Context(),
+			&config.Config{
+				TokenEnv: "GARM_LINODE_TEST_MISSING",
+			},
+		)
+		require.ErrorContains(t, err, "getting token: token_env: environment variable GARM_LINODE_TEST_MISSING is not set")
+	})
+
 	t.Run("Failure without configuration", func(t *testing.T) {
-		_, err := api.New(nil)
+		_, err := api.New(t.Context(), nil)
 		require.ErrorContains(t, err, "configuration is nil")
 	})
 }
+
+// fakeLinode is a fake Linode API answering the given responses in order,
+// then a successful one.
+type fakeLinode struct {
+	responses []fakeResponse
+	requests  []string
+}
+
+type fakeResponse struct {
+	status  int
+	headers map[string]string
+	reason  string
+}
+
+func (f *fakeLinode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, string(body))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(f.requests) > len(f.responses) {
+		fmt.Fprint(w, `{"id": 1234, "label": "garm-test", "status": "running"}`)
+		return
+	}
+
+	resp := f.responses[len(f.requests)-1]
+	for k, v := range resp.headers {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(resp.status)
+	fmt.Fprintf(w, `{"errors": [{"reason": %q}]}`, resp.reason)
+}
+
+func newFakeAPI(t *testing.T, maxAttempts int, responses ...fakeResponse) (api.LinodeAPI, *fakeLinode) {
+	t.Helper()
+
+	fake := &fakeLinode{responses: responses}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cli, err := api.New(t.Context(), &config.Config{
+		Token:          "foo",
+		APIURL:         server.URL,
+		APIMaxAttempts: maxAttempts,
+	})
+	require.NoError(t, err)
+
+	return cli, fake
+}
+
+func TestRetry(t *testing.T) {
+	tooManyRequests := fakeResponse{
+		status:  http.StatusTooManyRequests,
+		headers: map[string]string{"Retry-After": "0"},
+		reason:  "Too many requests",
+	}
+
+	t.Run("Retry-After", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, tooManyRequests, tooManyRequests)
+
+		instance, err := cli.GetInstance(context.Background(), 1234)
+		require.NoError(t, err)
+		assert.Equal(t, instance.ID, 1234)
+		assert.Len(t, fake.requests, 3)
+	})
+
+	t.Run("X-RateLimit-Reset", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, fakeResponse{
+			status: http.StatusTooManyRequests,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Unix(), 10),
+			},
+			reason: "Too many requests",
+		})
+
+		_, err := cli.GetInstance(context.Background(), 1234)
+		require.NoError(t, err)
+		assert.Len(t, fake.requests, 2)
+	})
+
+	t.Run("Backoff on server errors", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0,
+			fakeResponse{status: http.StatusBadGateway, reason: "Bad gateway"},
+			fakeResponse{status: http.StatusServiceUnavailable, reason: "Service unavailable"},
+		)
+
+		_, err := cli.GetInstance(context.Background(), 1234)
+		require.NoError(t, err)
+		assert.Len(t, fake.requests, 3)
+	})
+
+	t.Run("Linode busy", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, fakeResponse{status: http.StatusBadRequest, reason: "Linode busy."})
+
+		err := cli.BootInstance(context.Background(), 1234, 0)
+		require.NoError(t, err)
+		assert.Len(t, fake.requests, 2)
+	})
+
+	t.Run("Max attempts", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 2, tooManyRequests, tooManyRequests, tooManyRequests)
+
+		_, err := cli.GetInstance(context.Background(), 1234)
+		require.ErrorContains(t, err, "Too many requests")
+		assert.Len(t, fake.requests, 2)
+	})
+
+	t.Run("Replay the body of rate limited requests", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, tooManyRequests)
+
+		_, err := cli.CreateInstance(context.Background(), linodego.InstanceCreateOptions{Label: "garm-test"})
+		require.NoError(t, err)
+		require.Len(t, fake.requests, 2)
+		assert.Contains(t, fake.requests[1], `"label":"garm-test"`)
+		assert.Equal(t, fake.requests[1], fake.requests[0])
+	})
+
+	t.Run("No retry of non idempotent requests on server errors", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, fakeResponse{status: http.StatusInternalServerError, reason: "Internal server error"})
+
+		_, err := cli.CreateInstance(context.Background(), linodego.InstanceCreateOptions{Label: "garm-test"})
+		require.ErrorContains(t, err, "Internal server error")
+		assert.Len(t, fake.requests, 1)
+	})
+
+	t.Run("No retry during maintenance", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, fakeResponse{
+			status:  http.StatusServiceUnavailable,
+			headers: map[string]string{"X-Maintenance-Mode": "1"},
+			reason:  "Maintenance",
+		})
+
+		_, err := cli.GetInstance(context.Background(), 1234)
+		require.ErrorContains(t, err, "Maintenance")
+		assert.Len(t, fake.requests, 1)
+	})
+
+	t.Run("No retry of client errors", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, fakeResponse{status: http.StatusNotFound, reason: "Not found"})
+
+		_, err := cli.GetInstance(context.Background(), 1234)
+		require.True(t, linodego.IsNotFound(err))
+		assert.Len(t, fake.requests, 1)
+	})
+
+	t.Run("Context canceled while waiting", func(t *testing.T) {
+		cli, fake := newFakeAPI(t, 0, fakeResponse{
+			status:  http.StatusTooManyRequests,
+			headers: map[string]string{"Retry-After": "60"},
+			reason:  "Too many requests",
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := cli.GetInstance(ctx, 1234)
+		require.ErrorContains(t, err, "context deadline exceeded")
+		assert.Len(t, fake.requests, 1)
+	})
+}
+
+func TestImageCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"errors": [{"reason": "Internal server error"}]}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"id": "linode/ubuntu24.04", "label": "Ubuntu 24.04 LTS", "vendor": "Ubuntu"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	cli, err := api.New(t.Context(), &config.Config{
+		Token:          "foo",
+		APIURL:         server.URL,
+		APIMaxAttempts: 1,
+	})
+	require.NoError(t, err)
+
+	// Errors are not cached.
+	_, err = cli.GetImage(context.Background(), "linode/ubuntu24.04")
+	require.ErrorContains(t, err, "Internal server error")
+
+	for range 3 {
+		image, err := cli.GetImage(context.Background(), "linode/ubuntu24.04")
+		require.NoError(t, err)
+		assert.Equal(t, image.Label, "Ubuntu 24.04 LTS")
+	}
+
+	assert.Equal(t, requests, 2)
+}
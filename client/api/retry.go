@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of attempts of a request when
+	// not set in the configuration.
+	DefaultMaxAttempts = 5
+
+	// retryBaseDelay is the delay before the first retry, doubled on
+	// every following attempt.
+	retryBaseDelay = 250 * time.Millisecond
+	// retryMaxDelay caps the exponential backoff.
+	retryMaxDelay = 30 * time.Second
+	// retryMaxServerDelay caps the delay requested by the Linode API
+	// through the Retry-After and X-RateLimit-Reset headers.
+	retryMaxServerDelay = 2 * time.Minute
+
+	maintenanceModeHeader = "X-Maintenance-Mode"
+	rateLimitRemaining    = "X-RateLimit-Remaining"
+	rateLimitReset        = "X-RateLimit-Reset"
+)
+
+// retryTransport retries the requests rejected by the Linode API because
+// of rate limits or transient errors, with an exponential backoff and jitter.
+// Requests which may have been processed are only retried when idempotent.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		body = b
+	}
+
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(req.Context())
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.next.RoundTrip(r)
+		if attempt >= t.maxAttempts || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry returns true if the request can be sent again.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return isIdempotent(req.Method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		// The API answers 503 during maintenance events, they
+		// last longer than what is worth waiting for.
+		return resp.Header.Get(maintenanceModeHeader) == ""
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isIdempotent(req.Method)
+	case http.StatusBadRequest:
+		return isLinodeBusy(resp)
+	}
+
+	return false
+}
+
+// isLinodeBusy returns true if the API rejected the request because the
+// Linode has another operation in progress. The response body is restored.
+func isLinodeBusy(resp *http.Response) bool {
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	return err == nil && bytes.Contains(b, []byte("Linode busy."))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// retryDelay returns how long to wait before the next attempt. The delay
+// requested by the API takes precedence over the exponential backoff.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if d, ok := serverDelay(resp); ok {
+		return min(d, retryMaxServerDelay)
+	}
+
+	backoff := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+
+	// Jitter spreads the retries of concurrent provider invocations.
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// serverDelay returns the delay requested by the API, either through
+// Retry-After or, once the rate limit is exhausted, X-RateLimit-Reset.
+func serverDelay(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if date, err := http.ParseTime(v); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	if resp.Header.Get(rateLimitRemaining) == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get(rateLimitReset), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), 0), true
+		}
+	}
+
+	return 0, false
+}
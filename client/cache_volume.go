@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+const (
+	// cacheVolumeMaxCount bounds the number of cache volumes of a pool.
+	cacheVolumeMaxCount = 100
+	// cacheClaimAttempts is the number of attempts to claim a cache volume
+	// before the runner goes without one.
+	cacheClaimAttempts = 3
+)
+
+// cacheVolumeSpec describes the persistent Block Storage volumes of a pool.
+// Each runner claims a free one, which is released once the runner is
+// deleted, keeping its content for the next runners.
+type cacheVolumeSpec struct {
+	// Count is the number of cache volumes of the pool, created as needed.
+	Count int `json:"count" jsonschema:"minimum=1,maximum=100,description=Number of cache volumes of the pool\\, created as needed."`
+	volumeSpec
+}
+
+// validate checks the cache volume spec.
+func (v *cacheVolumeSpec) validate() error {
+	if v.Count < 1 || v.Count > cacheVolumeMaxCount {
+		return fmt.Errorf("cache volume count must be between 1 and %d", cacheVolumeMaxCount)
+	}
+
+	return v.volumeSpec.validate()
+}
+
+// cacheVolumeLabelPrefix returns the prefix of the labels of the cache
+// volumes of a pool, followed by their index.
+func cacheVolumeLabelPrefix(poolID string) string {
+	sum := sha256.Sum256([]byte(poolID))
+
+	return "garm-cache-" + hex.EncodeToString(sum[:])[:16] + "-"
+}
+
+// cacheVolumeMountScript returns the script mounting the cache volume the
+// runner claimed, which is not known when generating the user data. The
+// runner goes without cache when all the volumes are in use.
+func cacheVolumeMountScript(poolID string, spec *cacheVolumeSpec) (mountScript, error) {
+	device := volumeDevicePrefix + cacheVolumeLabelPrefix(poolID) + "*"
+
+	script, err := volumeMountScript(device, &spec.volumeSpec, true)
+	if err != nil {
+		return mountScript{}, err
+	}
+
+	return mountScript{name: "mount-cache-volume.sh", contents: script}, nil
+}
+
+// claimTag returns the tag of a cache volume claimed by an instance.
+func claimTag(id int) string {
+	return fmt.Sprintf("%s=%d", TagClaimedBy, id)
+}
+
+// claimedBy returns the ID of the instance which claimed a cache volume.
+func claimedBy(tags []string) (int, bool) {
+	for _, tag := range tags {
+		if v, ok := strings.CutPrefix(tag, TagClaimedBy+"="); ok {
+			id, err := strconv.Atoi(v)
+			return id, err == nil
+		}
+	}
+
+	return 0, false
+}
+
+// withClaim returns the tags of a cache volume claimed by the instance id,
+// 0 meaning released.
+func withClaim(tags []string, id int) []string {
+	tags = slices.DeleteFunc(slices.Clone(tags), func(tag string) bool {
+		return strings.HasPrefix(tag, TagClaimedBy+"=")
+	})
+
+	if id != 0 {
+		tags = append(tags, claimTag(id))
+	}
+
+	return tags
+}
+
+// listCacheVolumes returns the cache volumes of the pool.
+func (c *Linode) listCacheVolumes(ctx context.Context, poolID string) ([]linodego.Volume, error) {
+	opts, err := and(hasTag(TagCache), hasTag(poolTag(poolID)), hasTag(c.controllerTag())).listOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := c.api.ListVolumes(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing volumes from Linode API: %w", err)
+	}
+
+	return slices.DeleteFunc(volumes, func(v linodego.Volume) bool {
+		return !hasTags(v.Tags, TagCache, poolTag(poolID), c.controllerTag())
+	}), nil
+}
+
+// claimCacheVolume claims a free cache volume of the pool and attaches it
+// to the instance, which is not booted yet. A volume is created when none
+// is free and the pool has less than spec.Count of them. The instance goes
+// without cache volume, nil being returned, when all of them are in use.
+//
+// Tags cannot be updated atomically, a claim tags the volume with the
+// instance ID and checks the tag after a while: the last concurrent claim
+// written wins. Attaching the volume, which fails when it is attached to
+// another instance, settles the remaining races.
+func (c *Linode) claimCacheVolume(ctx context.Context, instance *linodego.Instance, poolID string, spec *cacheVolumeSpec) (*linodego.Volume, error) {
+	var createErr error
+	for range cacheClaimAttempts {
+		volumes, err := c.listCacheVolumes(ctx, poolID)
+		if err != nil {
+			return nil, err
+		}
+
+		free, err := c.freeCacheVolumes(ctx, volumes, instance.Region)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(free) == 0 {
+			if len(volumes) >= spec.Count {
+				return nil, nil
+			}
+
+			// Another runner may create a volume with the same label
+			// in the meantime, it is then tried again.
+			volume, err := c.createCacheVolume(ctx, instance, poolID, spec, volumes)
+			if err == nil {
+				return volume, nil
+			}
+
+			createErr = err
+			continue
+		}
+
+		// Picking a random volume spreads the concurrent claims.
+		volume, err := c.tryClaimCacheVolume(ctx, free[rand.N(len(free))], instance.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if volume != nil {
+			return volume, nil
+		}
+	}
+
+	return nil, createErr
+}
+
+// freeCacheVolumes returns the cache volumes which can be claimed by an
+// instance of the region. A volume claimed by an instance which no longer
+// exists, having failed to release it, is free.
+func (c *Linode) freeCacheVolumes(ctx context.Context, volumes []linodego.Volume, region string) ([]linodego.Volume, error) {
+	var free []linodego.Volume
+	for _, volume := range volumes {
+		if volume.Region != region || volume.LinodeID != nil {
+			continue
+		}
+
+		if id, ok := claimedBy(volume.Tags); ok {
+			_, err := c.api.GetInstance(ctx, id)
+			if err == nil {
+				continue
+			}
+
+			if !linodego.IsNotFound(err) {
+				return nil, fmt.Errorf("getting instance from Linode API: %w", err)
+			}
+		}
+
+		free = append(free, volume)
+	}
+
+	return free, nil
+}
+
+// createCacheVolume creates a cache volume claimed by and attached to the
+// instance, labelled with the first index unused in the pool.
+func (c *Linode) createCacheVolume(ctx context.Context, instance *linodego.Instance, poolID string, spec *cacheVolumeSpec, volumes []linodego.Volume) (*linodego.Volume, error) {
+	prefix := cacheVolumeLabelPrefix(poolID)
+	index := 0
+	for slices.ContainsFunc(volumes, func(v linodego.Volume) bool {
+		return v.Label == prefix+strconv.Itoa(index)
+	}) {
+		index++
+	}
+
+	volume, err := c.api.CreateVolume(ctx, linodego.VolumeCreateOptions{
+		Label:    prefix + strconv.Itoa(index),
+		LinodeID: instance.ID,
+		Size:     spec.Size,
+		Tags: []string{
+			TagCache,
+			poolTag(poolID),
+			c.controllerTag(),
+			claimTag(instance.ID),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating cache volume from Linode API: %w", err)
+	}
+
+	return volume, nil
+}
+
+// tryClaimCacheVolume claims a free cache volume for the instance id and
+// attaches it. It returns nil if another instance claimed it first.
+func (c *Linode) tryClaimCacheVolume(ctx context.Context, volume linodego.Volume, id int) (*linodego.Volume, error) {
+	tags := withClaim(volume.Tags, id)
+	if _, err := c.api.UpdateVolume(ctx, volume.ID, linodego.VolumeUpdateOptions{Tags: &tags}); err != nil {
+		return nil, fmt.Errorf("tagging volume %d from Linode API: %w", volume.ID, err)
+	}
+
+	// The concurrent claims are given the poll interval to be written.
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Duration(c.getWait(extraSpecs{}).PollInterval)):
+	}
+
+	claimed, err := c.api.GetVolume(ctx, volume.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting volume %d from Linode API: %w", volume.ID, err)
+	}
+
+	if owner, _ := claimedBy(claimed.Tags); owner != id {
+		return nil, nil
+	}
+
+	attached, err := c.api.AttachVolume(ctx, volume.ID, &linodego.VolumeAttachOptions{LinodeID: id})
+	if err != nil {
+		if !linodego.ErrHasStatus(err, http.StatusBadRequest) {
+			return nil, fmt.Errorf("attaching volume %d from Linode API: %w", volume.ID, err)
+		}
+
+		// The volume was attached to another instance, whose claim
+		// was overwritten by this one.
+		if claimed, err := c.api.GetVolume(ctx, volume.ID); err == nil && claimed.LinodeID != nil {
+			tags := withClaim(claimed.Tags, *claimed.LinodeID)
+			_, _ = c.api.UpdateVolume(ctx, volume.ID, linodego.VolumeUpdateOptions{Tags: &tags})
+		}
+
+		return nil, nil
+	}
+
+	return attached, nil
+}
+
+// releaseCacheVolume releases the cache volume claimed by a deleted
+// instance once detached, for the next runners to claim it.
+func (c *Linode) releaseCacheVolume(ctx context.Context, id int) error {
+	volume, err := c.waitForVolumeDetached(ctx, id)
+	if err != nil || volume == nil {
+		return err
+	}
+
+	tags := withClaim(volume.Tags, 0)
+	if _, err := c.api.UpdateVolume(ctx, id, linodego.VolumeUpdateOptions{Tags: &tags}); err != nil {
+		return fmt.Errorf("untagging volume from Linode API: %w", err)
+	}
+
+	return nil
+}
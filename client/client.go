@@ -4,13 +4,17 @@ package client
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/cloudbase/garm-provider-common/cloudconfig"
+	gErrors "github.com/cloudbase/garm-provider-common/errors"
 	"github.com/cloudbase/garm-provider-common/params"
 	"github.com/cloudbase/garm-provider-common/util"
 	"github.com/linode/linodego"
@@ -22,6 +26,24 @@ import (
 const (
 	TagPool       = "garm-pool-id"
 	TagController = "garm-controller-id"
+	// TagFailed marks an instance which failed to be created and could not
+	// be deleted, it is deleted along with the other controller instances.
+	TagFailed = "garm-failed"
+	// TagCache marks the persistent cache volumes of a pool.
+	TagCache = "garm-cache"
+	// TagClaimedBy holds the ID of the instance which claimed a cache
+	// volume.
+	TagClaimedBy = "garm-claimed-by"
+	// TagImage holds the image of an instance created with custom disks,
+	// which Linode does not report as the instance image.
+	TagImage = "garm-image"
+
+	// rollbackTimeout bounds the cleanup of an instance which failed to be
+	// created, independently of the request context.
+	rollbackTimeout = 2 * time.Minute
+	// defaultDeleteConcurrency is the number of instances deleted at once
+	// by RemoveAllInstances when not set in the configuration.
+	defaultDeleteConcurrency = 10
 )
 
 type Linode struct {
@@ -39,7 +61,7 @@ func New(cfg *config.Config, a api.LinodeAPI, controllerID string) (*Linode, err
 	}, nil
 }
 
-func (c *Linode) CreateInstance(ctx context.Context, bootstrapParams params.BootstrapInstance) (*linodego.Instance, error) {
+func (c *Linode) CreateInstance(ctx context.Context, bootstrapParams params.BootstrapInstance) (_ *linodego.Instance, err error) {
 	tools, err := util.GetTools(bootstrapParams.OSType, bootstrapParams.OSArch, bootstrapParams.Tools)
 	if err != nil {
 		return nil, fmt.Errorf("getting tools: %w", err)
@@ -50,9 +72,12 @@ func (c *Linode) CreateInstance(ctx context.Context, bootstrapParams params.Boot
 		return nil, fmt.Errorf("getting extra specs: %w", err)
 	}
 
-	bootstrapParams.UserDataOptions.ExtraPackages = extraSpecs.ExtraPackages
+	placementGroup := c.config.PlacementGroup
+	if extraSpecs.PlacementGroup != nil {
+		placementGroup = extraSpecs.PlacementGroup
+	}
 
-	userData, err := cloudconfig.GetCloudConfig(bootstrapParams, tools, bootstrapParams.Name)
+	userData, err := getUserData(bootstrapParams, tools, extraSpecs)
 	if err != nil {
 		return nil, fmt.Errorf("generating userdata: %w", err)
 	}
@@ -62,146 +87,754 @@ func (c *Linode) CreateInstance(ctx context.Context, bootstrapParams params.Boot
 		return nil, fmt.Errorf("generating root password: %w", err)
 	}
 
-	booted := true
+	network := c.config.Network
+	if extraSpecs.Network != nil {
+		network = extraSpecs.Network
+	}
+
+	firewallID, err := c.getFirewallID(ctx, extraSpecs)
+	if err != nil {
+		return nil, fmt.Errorf("getting firewall: %w", err)
+	}
+
+	var layout diskLayout
+	if extraSpecs.Disks != nil {
+		layout, err = c.getDiskLayout(ctx, extraSpecs.Disks, bootstrapParams.Flavor)
+		if err != nil {
+			return nil, fmt.Errorf("getting disk layout: %w", err)
+		}
+	}
+
+	// The volumes have to be attached before the instance boots to be set
+	// up by the user data, the disks created before it can boot.
+	booted := extraSpecs.Volume == nil && extraSpecs.CacheVolume == nil && extraSpecs.Disks == nil
 
 	opts := linodego.InstanceCreateOptions{
-		Booted: &booted,
-		Image:  bootstrapParams.Image,
-		Label:  bootstrapParams.Name,
+		Booted:     &booted,
+		FirewallID: firewallID,
+		Image:      bootstrapParams.Image,
+		Label:      bootstrapParams.Name,
 		Metadata: &linodego.InstanceMetadataOptions{
-			UserData: base64.StdEncoding.EncodeToString([]byte(userData)),
+			UserData: userData,
 		},
-		Region:   c.config.Region,
 		RootPass: password,
 		Tags: []string{
-			fmt.Sprintf("%s=%s", TagPool, bootstrapParams.PoolID),
-			fmt.Sprintf("%s=%s", TagController, c.id),
+			poolTag(bootstrapParams.PoolID),
+			c.controllerTag(),
 		},
 		Type: bootstrapParams.Flavor,
 	}
 
-	instance, err := c.api.CreateInstance(ctx, opts)
-	if err != nil {
-		return nil, fmt.Errorf("creating instance: %w", err)
+	if extraSpecs.StackScriptID != 0 {
+		script, err := c.getStackScript(ctx, extraSpecs.StackScriptID, bootstrapParams.Image)
+		if err != nil {
+			return nil, fmt.Errorf("getting StackScript: %w", err)
+		}
+
+		opts.StackScriptID = script.ID
+		opts.StackScriptData, err = stackScriptData(script, extraSpecs.StackScriptData, bootstrapParams)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// We wait for the instance to be provisioned, booted and running.
-	if err := waitUntilReady(5*time.Minute, 5*time.Second, func() (bool, error) {
-		instance, err = c.api.GetInstance(ctx, instance.ID)
+	// With a custom disk layout, the instance is created without disks,
+	// the image being deployed on its root disk.
+	var rootDisk linodego.InstanceDiskCreateOptions
+	if extraSpecs.Disks != nil {
+		rootDisk = linodego.InstanceDiskCreateOptions{
+			Image:           opts.Image,
+			RootPass:        opts.RootPass,
+			StackscriptID:   opts.StackScriptID,
+			StackscriptData: opts.StackScriptData,
+		}
+
+		opts.Image = ""
+		opts.RootPass = ""
+		opts.StackScriptID = 0
+		opts.StackScriptData = nil
+		opts.Tags = append(opts.Tags, imageTag(bootstrapParams.Image))
+	}
+
+	// Regions are tried in order, moving to the next one only when
+	// Linode lacks capacity in the current one, the VPC is not there or
+	// the placement group cannot host the runner.
+	var (
+		instance   *linodego.Instance
+		interfaces []linodego.InstanceConfigInterfaceCreateOptions
+		errs       []error
+		abandoned  []int
+	)
+	for _, region := range c.getRegions(extraSpecs) {
+		interfaces, err = c.getInterfaces(ctx, network, region)
+		if err != nil {
+			if !errors.Is(err, errNetworkUnavailable) {
+				return nil, fmt.Errorf("getting network interfaces: %w", err)
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", region, err))
+			continue
+		}
+
+		opts.Region = region
+		opts.Interfaces = nil
+		opts.InterfaceGeneration = ""
+		if len(interfaces) > 0 {
+			// The interfaces belong to the configuration profile,
+			// created along with the disks when they are custom.
+			if extraSpecs.Disks == nil {
+				opts.Interfaces = interfaces
+			}
+			opts.InterfaceGeneration = linodego.GenerationLegacyConfig
+		}
+
+		opts.PlacementGroup, err = c.getPlacementGroup(ctx, placementGroup, bootstrapParams.PoolID, region)
 		if err != nil {
-			return false, fmt.Errorf("getting instance: %w", err)
+			if !errors.Is(err, errPlacementGroupUnavailable) {
+				return nil, fmt.Errorf("getting placement group: %w", err)
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", region, err))
+			continue
+		}
+
+		instance, err = c.api.CreateInstance(ctx, opts)
+		if err != nil && opts.PlacementGroup != nil && placementGroup.ID == 0 && linodego.IsNotFound(err) {
+			// The managed group was deleted along with the last runner
+			// of the pool in the meantime, resolving it again creates
+			// it anew.
+			opts.PlacementGroup, err = c.getPlacementGroup(ctx, placementGroup, bootstrapParams.PoolID, region)
+			if err == nil {
+				instance, err = c.api.CreateInstance(ctx, opts)
+			}
+		}
+
+		managedGroup := 0
+		if opts.PlacementGroup != nil && placementGroup.ID == 0 {
+			managedGroup = opts.PlacementGroup.ID
+		}
+
+		if err != nil && opts.PlacementGroup != nil && isPlacementGroupError(err) &&
+			placementGroupFallback(placementGroup) == config.PlacementGroupFallbackUngrouped {
+			opts.PlacementGroup = nil
+			instance, err = c.api.CreateInstance(ctx, opts)
+		}
+
+		if err == nil {
+			break
+		}
+
+		if managedGroup != 0 {
+			abandoned = append(abandoned, managedGroup)
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", region, err))
+		if !isCapacityError(err) && !isPlacementGroupError(err) && !errors.Is(err, errPlacementGroupUnavailable) {
+			break
+		}
+	}
+
+	// The managed groups of the regions given up on are not left behind
+	// empty, the runner being created elsewhere or not at all.
+	c.releaseAbandonedPlacementGroups(ctx, abandoned)
+
+	if instance == nil {
+		return nil, fmt.Errorf("creating instance: %w", errors.Join(errs...))
+	}
+
+	// GARM has no notion of regions, the failover is only recorded in the
+	// logs.
+	if len(errs) > 0 {
+		slog.InfoContext(ctx, "instance created in a failover region", "instance", instance.ID, "region", opts.Region, "skipped", errors.Join(errs...))
+	}
+
+	// GARM never learns about an instance if this function fails, it has
+	// to be rolled back so that it does not keep running.
+	id := instance.ID
+	var volume, cacheVolume *linodego.Volume
+	defer func() {
+		if err != nil {
+			err = errors.Join(err,
+				c.instanceFaultError(ctx, id),
+				c.rollbackInstance(ctx, id, opts.Tags),
+				c.rollbackVolume(ctx, volume),
+				c.rollbackCacheVolume(ctx, cacheVolume),
+			)
+		}
+	}()
+
+	// The volumes are attached to the configuration profile, which has to
+	// exist first.
+	configID := 0
+	if extraSpecs.Disks != nil {
+		cfg, err := c.createDisks(ctx, id, layout, rootDisk, extraSpecs.Disks.kernel(bootstrapParams.Image), interfaces, c.getWait(extraSpecs))
+		if err != nil {
+			return nil, fmt.Errorf("creating disks: %w", err)
+		}
+
+		configID = cfg.ID
+	}
+
+	if extraSpecs.Volume != nil {
+		volume, err = c.createVolume(ctx, instance, extraSpecs.Volume, opts.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("creating volume: %w", err)
+		}
+	}
+
+	if extraSpecs.CacheVolume != nil {
+		cacheVolume, err = c.claimCacheVolume(ctx, instance, bootstrapParams.PoolID, extraSpecs.CacheVolume)
+		if err != nil {
+			return nil, fmt.Errorf("claiming cache volume: %w", err)
+		}
+	}
+
+	if !booted {
+		// The custom disks boot with their configuration profile, a
+		// config ID of 0 otherwise lets Linode boot the one the volumes
+		// were attached to.
+		if err := c.api.BootInstance(ctx, id, configID); err != nil {
+			return nil, fmt.Errorf("booting instance from Linode API: %w", err)
 		}
+	}
+
+	wait := c.getWait(extraSpecs)
+	if wait.Until == config.WaitUntilProvisioning {
+		return instance, nil
+	}
 
-		return instance.Status == linodego.InstanceRunning, nil
-	}); err != nil {
+	// We wait for the instance to be provisioned, booted and running.
+	instance, err = c.waitForStatus(ctx, id, linodego.InstanceRunning, time.Duration(wait.Timeout), time.Duration(wait.PollInterval))
+	if err != nil {
 		return nil, fmt.Errorf("getting instance running: %w", err)
 	}
 
 	return instance, nil
 }
 
+// rollbackContext returns the context to clean up after a failed creation,
+// bounded by rollbackTimeout. It is not canceled along with the request
+// context, which may be the reason of the failure.
+func rollbackContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+}
+
+// instanceFaultError returns the failed events of an instance which failed
+// to be created, explaining why it failed. Looking them up is best effort,
+// its errors would hide the creation error.
+func (c *Linode) instanceFaultError(ctx context.Context, id int) error {
+	ctx, cancel := rollbackContext(ctx)
+	defer cancel()
+
+	faults, err := c.GetInstanceFaults(ctx, id)
+	if err != nil || faults[id] == "" {
+		return nil
+	}
+
+	return fmt.Errorf("instance %d events:\n%s", id, faults[id])
+}
+
+// rollbackVolume deletes the volume of an instance which failed to be
+// created, once detached from the deleted instance. A volume left behind is
+// deleted along with the other controller volumes.
+func (c *Linode) rollbackVolume(ctx context.Context, volume *linodego.Volume) error {
+	if volume == nil {
+		return nil
+	}
+
+	ctx, cancel := rollbackContext(ctx)
+	defer cancel()
+
+	if err := c.deleteVolume(ctx, volume.ID); err != nil {
+		return fmt.Errorf("rolling back volume %d: %w", volume.ID, err)
+	}
+
+	return nil
+}
+
+// rollbackCacheVolume releases the cache volume claimed by an instance
+// which failed to be created.
+func (c *Linode) rollbackCacheVolume(ctx context.Context, volume *linodego.Volume) error {
+	if volume == nil {
+		return nil
+	}
+
+	ctx, cancel := rollbackContext(ctx)
+	defer cancel()
+
+	if err := c.releaseCacheVolume(ctx, volume.ID); err != nil {
+		return fmt.Errorf("rolling back cache volume %d: %w", volume.ID, err)
+	}
+
+	return nil
+}
+
+// rollbackInstance deletes an instance which failed to be created. When it
+// cannot be deleted, it is tagged with TagFailed so it can be found and
+// deleted later.
+func (c *Linode) rollbackInstance(ctx context.Context, id int, tags []string) error {
+	ctx, cancel := rollbackContext(ctx)
+	defer cancel()
+
+	err := c.api.DeleteInstance(ctx, id)
+	if err == nil || linodego.IsNotFound(err) {
+		return nil
+	}
+
+	tags = append(slices.Clone(tags), TagFailed)
+	if _, tErr := c.api.UpdateInstance(ctx, id, linodego.InstanceUpdateOptions{Tags: &tags}); tErr != nil {
+		return fmt.Errorf("rolling back instance %d: deleting: %w, tagging as %s: %w", id, err, TagFailed, tErr)
+	}
+
+	return fmt.Errorf("rolling back instance %d (tagged as %s): deleting: %w", id, TagFailed, err)
+}
+
+// DeleteInstance deletes the instance along with its volumes, its cache
+// volume being released instead, and its managed placement group once
+// empty. An instance which no longer exists is considered deleted.
 func (c *Linode) DeleteInstance(ctx context.Context, ID string) error {
-	var id int
-	id, err := strconv.Atoi(ID)
+	instance, err := c.getInstance(ctx, ID)
 	if err != nil {
-		i, err := c.GetInstanceID(ctx, ID)
-		if err != nil {
-			return fmt.Errorf("getting instance ID by its name: %w", err)
+		if errors.Is(err, gErrors.ErrNotFound) {
+			return nil
 		}
 
-		id = i
+		return err
+	}
+
+	volumes, err := c.listInstanceVolumes(ctx, instance.ID)
+	if err != nil {
+		return fmt.Errorf("listing instance volumes: %w", err)
+	}
+
+	if err := c.deleteInstance(ctx, instance.ID); err != nil {
+		return err
+	}
+
+	if err := c.releaseVolumes(ctx, volumes); err != nil {
+		return fmt.Errorf("releasing instance volumes: %w", err)
 	}
 
-	if err := c.api.DeleteInstance(ctx, id); err != nil {
+	if err := c.releasePlacementGroup(ctx, instance); err != nil {
+		return fmt.Errorf("releasing placement group: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Linode) deleteInstance(ctx context.Context, id int) error {
+	if err := c.api.DeleteInstance(ctx, id); err != nil && !linodego.IsNotFound(err) {
 		return fmt.Errorf("deleting instance from Linode API: %w", err)
 	}
 
 	return nil
 }
 
+// GetInstance returns the instance of this controller, the error matches
+// gErrors.ErrNotFound when it does not exist.
 func (c *Linode) GetInstance(ctx context.Context, ID string) (*linodego.Instance, error) {
-	var id int
-	id, err := strconv.Atoi(ID)
+	return c.getInstance(ctx, ID)
+}
+
+// GetInstanceIPAddresses returns all the IP addresses of the instance,
+// including the VPC ones which are not part of the instance itself.
+func (c *Linode) GetInstanceIPAddresses(ctx context.Context, id int) (*linodego.InstanceIPAddressResponse, error) {
+	ips, err := c.api.GetInstanceIPAddresses(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance IP addresses from Linode API: %w", err)
+	}
+
+	return ips, nil
+}
+
+// StopInstance shuts down the instance and waits for it to be offline.
+// Linode only exposes a single shutdown action, without any hard power off,
+// which it refuses while the instance is busy (booting, shutting down,
+// migrating, etc.). A graceful stop only shuts down a running instance,
+// giving the guest the wait timeout to power off. A forced stop skips this
+// guard: it waits for a busy instance to settle before shutting it down, and
+// for a shutdown in progress to complete rather than issuing another one.
+func (c *Linode) StopInstance(ctx context.Context, ID string, force bool) error {
+	instance, err := c.getInstance(ctx, ID)
 	if err != nil {
-		i, err := c.GetInstanceID(ctx, ID)
+		return err
+	}
+	id := instance.ID
+
+	if instance.Status == linodego.InstanceOffline {
+		return nil
+	}
+
+	wait := c.getWait(extraSpecs{})
+	if force {
+		return c.forceStop(ctx, instance, wait)
+	}
+
+	if instance.Status != linodego.InstanceRunning {
+		return fmt.Errorf("instance %d is %s, refusing to shut it down gracefully", id, instance.Status)
+	}
+
+	if err := c.api.ShutdownInstance(ctx, id); err != nil {
+		return fmt.Errorf("shutting down instance from Linode API: %w", err)
+	}
+
+	if _, err := c.waitForStatus(ctx, id, linodego.InstanceOffline, time.Duration(wait.Timeout), time.Duration(wait.PollInterval)); err != nil {
+		return fmt.Errorf("getting instance offline: %w", err)
+	}
+
+	return nil
+}
+
+// forceStop shuts down the instance whatever its status and waits for it to
+// be offline.
+func (c *Linode) forceStop(ctx context.Context, instance *linodego.Instance, wait config.Wait) error {
+	id := instance.ID
+
+	if instance.Status != linodego.InstanceShuttingDown {
+		if instance.Status != linodego.InstanceRunning {
+			settled, err := c.waitForSettled(ctx, id, time.Duration(wait.Timeout), time.Duration(wait.PollInterval))
+			if err != nil {
+				return fmt.Errorf("getting instance settled: %w", err)
+			}
+
+			if settled.Status == linodego.InstanceOffline {
+				return nil
+			}
+		}
+
+		if err := c.api.ShutdownInstance(ctx, id); err != nil {
+			return fmt.Errorf("shutting down instance from Linode API: %w", err)
+		}
+	}
+
+	if _, err := c.waitForStatus(ctx, id, linodego.InstanceOffline, time.Duration(wait.Timeout), time.Duration(wait.PollInterval)); err != nil {
+		return fmt.Errorf("getting instance offline: %w", err)
+	}
+
+	return nil
+}
+
+// StartInstance boots the instance and waits for it to be running. A busy
+// instance is waited for to settle first, as Linode refuses to boot it.
+func (c *Linode) StartInstance(ctx context.Context, ID string) error {
+	instance, err := c.getInstance(ctx, ID)
+	if err != nil {
+		return err
+	}
+	id := instance.ID
+
+	wait := c.getWait(extraSpecs{})
+	if instance.Status != linodego.InstanceRunning && instance.Status != linodego.InstanceOffline {
+		instance, err = c.waitForSettled(ctx, id, time.Duration(wait.Timeout), time.Duration(wait.PollInterval))
 		if err != nil {
-			return nil, fmt.Errorf("getting instance ID by its name: %w", err)
+			return fmt.Errorf("getting instance settled: %w", err)
 		}
+	}
+
+	if instance.Status == linodego.InstanceRunning {
+		return nil
+	}
 
-		id = i
+	// A config ID of 0 lets Linode boot the last used configuration profile.
+	if err := c.api.BootInstance(ctx, id, 0); err != nil {
+		return fmt.Errorf("booting instance from Linode API: %w", err)
 	}
 
-	instance, err := c.api.GetInstance(ctx, id)
+	if _, err := c.waitForStatus(ctx, id, linodego.InstanceRunning, time.Duration(wait.Timeout), time.Duration(wait.PollInterval)); err != nil {
+		return fmt.Errorf("getting instance running: %w", err)
+	}
+
+	return nil
+}
+
+// ValidatePoolInfo checks the pool image, flavor and extra specs against the
+// Linode catalog and account, so a typo is caught before any runner is created.
+// With several regions, the pool is valid as long as one of them can host it.
+// The checks needing the image or the flavor are skipped when they are not
+// known.
+func (c *Linode) ValidatePoolInfo(ctx context.Context, image, flavor string, data json.RawMessage) error {
+	spec, err := parseExtraSpecs(data)
 	if err != nil {
-		return nil, fmt.Errorf("getting instance from Linode API: %w", err)
+		return fmt.Errorf("getting extra specs: %w", err)
+	}
+
+	firewallID := spec.FirewallID
+	if firewallID == 0 {
+		firewallID = c.config.FirewallID
+	}
+
+	if firewallID != 0 {
+		if _, err := c.api.GetFirewall(ctx, firewallID); err != nil {
+			if linodego.IsNotFound(err) {
+				return fmt.Errorf("firewall %d does not exist", firewallID)
+			}
+
+			return fmt.Errorf("getting firewall from Linode API: %w", err)
+		}
+	}
+
+	placementGroup := c.config.PlacementGroup
+	if spec.PlacementGroup != nil {
+		placementGroup = spec.PlacementGroup
+	}
+
+	if placementGroup != nil && placementGroup.ID != 0 {
+		if _, err := c.api.GetPlacementGroup(ctx, placementGroup.ID); err != nil {
+			if linodego.IsNotFound(err) {
+				return fmt.Errorf("placement group %d does not exist", placementGroup.ID)
+			}
+
+			return fmt.Errorf("getting placement group from Linode API: %w", err)
+		}
+	}
+
+	// The image support of the StackScript is only checked when the
+	// image is known.
+	if spec.StackScriptID != 0 {
+		script, err := c.getStackScript(ctx, spec.StackScriptID, image)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stackScriptData(script, spec.StackScriptData, params.BootstrapInstance{}); err != nil {
+			return err
+		}
+	}
+
+	// GARM does not always provide the image and the flavor.
+	if image == "" || flavor == "" {
+		return nil
+	}
+
+	img, err := c.api.GetImage(ctx, image)
+	if err != nil {
+		if linodego.IsNotFound(err) {
+			return fmt.Errorf("image %s does not exist", image)
+		}
+
+		return fmt.Errorf("getting image from Linode API: %w", err)
+	}
+
+	if img.Status != linodego.ImageStatusAvailable {
+		return fmt.Errorf("image %s is not available (status: %s)", image, img.Status)
+	}
+
+	if strings.HasPrefix(image, privateImagePrefix) {
+		if err := checkImageOwner(img); err != nil {
+			return err
+		}
+	}
+
+	typ, err := c.api.GetType(ctx, flavor)
+	if err != nil {
+		if linodego.IsNotFound(err) {
+			return fmt.Errorf("flavor %s does not exist", flavor)
+		}
+
+		return fmt.Errorf("getting type from Linode API: %w", err)
+	}
+
+	var errs []error
+	for _, region := range c.getRegions(spec) {
+		if err := c.validateRegion(ctx, img, flavor, region); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == len(c.getRegions(spec)) {
+		return errors.Join(errs...)
+	}
+
+	if spec.Disks != nil {
+		layout, err := spec.Disks.layout(typ.Disk)
+		if err != nil {
+			return fmt.Errorf("%s disk layout: %w", flavor, err)
+		}
+
+		if img.Size > layout.root {
+			return fmt.Errorf("image %s (%d MB) does not fit on the root disk (%d MB)", image, img.Size, layout.root)
+		}
+	} else if img.Size > typ.Disk {
+		return fmt.Errorf("image %s (%d MB) does not fit on the %s disk (%d MB)", image, img.Size, flavor, typ.Disk)
+	}
+
+	return nil
+}
+
+// validateRegion checks that the image and the plan are available in the region.
+func (c *Linode) validateRegion(ctx context.Context, img *linodego.Image, flavor, region string) error {
+	// Public images are available everywhere, private images are only
+	// available in the regions they have been replicated to.
+	if len(img.Regions) > 0 && !slices.ContainsFunc(img.Regions, func(r linodego.ImageRegion) bool {
+		return r.Region == region && r.Status == linodego.ImageRegionStatusAvailable
+	}) {
+		return fmt.Errorf("image %s is not available in %s", img.ID, region)
+	}
+
+	// The availability endpoint only lists plans with a restricted availability,
+	// a plan not listed is offered in the region.
+	availability, err := c.api.GetRegionAvailability(ctx, region)
+	if err != nil {
+		return fmt.Errorf("getting region availability from Linode API: %w", err)
+	}
+
+	for _, a := range availability {
+		if a.Plan == flavor && !a.Available {
+			return fmt.Errorf("%s is not available in %s", flavor, region)
+		}
+	}
+
+	return nil
+}
+
+// getInstance returns an instance of this controller, ID being either the
+// numerical Linode ID or the instance label. The instances of the other
+// controllers sharing the account are reported as not found, like the
+// instances which do not exist, with an error matching gErrors.ErrNotFound.
+func (c *Linode) getInstance(ctx context.Context, ID string) (*linodego.Instance, error) {
+	if id, err := strconv.Atoi(ID); err == nil {
+		instance, err := c.api.GetInstance(ctx, id)
+		if err != nil {
+			if linodego.IsNotFound(err) {
+				return nil, gErrors.NewNotFoundError("instance %d not found", id)
+			}
+
+			return nil, fmt.Errorf("getting instance from Linode API: %w", err)
+		}
+
+		if !hasTags(instance.Tags, c.controllerTag()) {
+			return nil, gErrors.NewNotFoundError("instance %d not found in this controller", id)
+		}
+
+		return instance, nil
+	}
+
+	instance, err := c.getInstanceByLabel(ctx, ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance ID by its name: %w", err)
 	}
 
 	return instance, nil
 }
 
+// GetInstanceID returns the ID of the instance of this controller labelled
+// name. It fails when several instances match.
 func (c *Linode) GetInstanceID(ctx context.Context, name string) (int, error) {
-	f := map[string]string{
-		"label": name,
+	instance, err := c.getInstanceByLabel(ctx, name)
+	if err != nil {
+		return -1, err
 	}
-	filter, err := json.Marshal(f)
+
+	return instance.ID, nil
+}
+
+// getInstanceByLabel returns the instance of this controller labelled name.
+func (c *Linode) getInstanceByLabel(ctx context.Context, name string) (*linodego.Instance, error) {
+	opts, err := and(labelIs(name), hasTag(c.controllerTag())).listOptions()
 	if err != nil {
-		return -1, fmt.Errorf("marshalling filter: %w", err)
+		return nil, err
 	}
 
-	instances, err := c.api.ListInstances(ctx, &linodego.ListOptions{
-		Filter: string(filter),
-	})
+	instances, err := c.api.ListInstances(ctx, opts)
 	if err != nil {
-		return -1, fmt.Errorf("listing instances from the API: %w", err)
+		return nil, fmt.Errorf("listing instances from the API: %w", err)
 	}
 
-	if len(instances) == 0 {
-		return -1, fmt.Errorf("no instances matching this name: %s", name)
+	instances = slices.DeleteFunc(instances, func(i linodego.Instance) bool {
+		return i.Label != name || !hasTags(i.Tags, c.controllerTag())
+	})
+
+	switch len(instances) {
+	case 0:
+		return nil, gErrors.NewNotFoundError("no instances matching this name: %s", name)
+	case 1:
+		return &instances[0], nil
 	}
 
-	return instances[0].ID, nil
+	return nil, fmt.Errorf("%d instances matching this name: %s", len(instances), name)
 }
 
+// ListInstances returns the instances of the pool created by this controller.
 func (c *Linode) ListInstances(ctx context.Context, poolID string) ([]linodego.Instance, error) {
-	f := map[string]string{
-		"tags": fmt.Sprintf("%s=%s", TagPool, poolID),
-	}
-	filter, err := json.Marshal(f)
+	opts, err := and(hasTag(poolTag(poolID)), hasTag(c.controllerTag())).listOptions()
 	if err != nil {
-		return nil, fmt.Errorf("marshalling filter: %w", err)
+		return nil, err
 	}
 
-	instances, err := c.api.ListInstances(ctx, &linodego.ListOptions{
-		Filter: string(filter),
-	})
+	instances, err := c.api.ListInstances(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("getting instances list from Linode API: %w", err)
 	}
 
-	return instances, nil
+	return slices.DeleteFunc(instances, func(i linodego.Instance) bool {
+		return !hasTags(i.Tags, poolTag(poolID), c.controllerTag())
+	}), nil
 }
 
 func (c *Linode) RemoveAllInstances(ctx context.Context) error {
-	f := map[string]string{
-		"tags": fmt.Sprintf("%s=%s", TagController, c.id),
+	opts, err := hasTag(c.controllerTag()).listOptions()
+	if err != nil {
+		return err
 	}
-	filter, err := json.Marshal(f)
+
+	instances, err := c.api.ListInstances(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("marshalling filter: %w", err)
+		return fmt.Errorf("getting instances list from Linode API: %w", err)
 	}
 
-	instances, err := c.api.ListInstances(ctx, &linodego.ListOptions{
-		Filter: string(filter),
+	instances = slices.DeleteFunc(instances, func(i linodego.Instance) bool {
+		return !hasTags(i.Tags, c.controllerTag())
 	})
+
+	concurrency := c.config.DeleteConcurrency
+	if concurrency == 0 {
+		concurrency = defaultDeleteConcurrency
+	}
+
+	// Instances are deleted concurrently, a failure does not prevent the
+	// other instances from being deleted.
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		errs = make([]error, len(instances))
+	)
+	for i, instance := range instances {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := c.deleteInstance(ctx, instance.ID); err != nil {
+				errs[i] = fmt.Errorf("deleting instance %d: %w", instance.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The volumes, the managed placement groups and the managed firewalls
+	// cannot be deleted while instances use them.
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	volumes, err := c.listVolumes(ctx)
 	if err != nil {
-		return fmt.Errorf("getting instances list from Linode API: %w", err)
+		return fmt.Errorf("listing volumes: %w", err)
 	}
 
-	for _, instance := range instances {
-		id := strconv.Itoa(instance.ID)
-		if err := c.DeleteInstance(ctx, id); err != nil {
-			return fmt.Errorf("deleting instance %s: %w", id, err)
-		}
+	if err := c.deleteVolumes(ctx, volumes); err != nil {
+		return fmt.Errorf("removing volumes: %w", err)
+	}
+
+	if err := c.removeManagedPlacementGroups(ctx); err != nil {
+		return fmt.Errorf("removing managed placement groups: %w", err)
+	}
+
+	if err := c.removeManagedFirewalls(ctx); err != nil {
+		return fmt.Errorf("removing managed firewalls: %w", err)
 	}
 
 	return nil
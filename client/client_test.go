@@ -3,25 +3,75 @@
 package client_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/cloudbase/garm-provider-common/cloudconfig"
+	gErrors "github.com/cloudbase/garm-provider-common/errors"
 	"github.com/cloudbase/garm-provider-common/params"
+	ignition "github.com/coreos/ignition/v2/config/v3_4"
+	"github.com/coreos/ignition/v2/config/v3_4/types"
 	"github.com/linode/linodego"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 
 	"github.com/flatcar/garm-provider-linode/client"
 	"github.com/flatcar/garm-provider-linode/config"
 )
 
 const (
-	MockCreateInstance = "create_instance"
-	MockDeleteInstance = "delete_instance"
-	MockGetInstance    = "get_instance"
-	MockListInstances  = "list_instances"
+	MockAttachVolume          = "attach_volume"
+	MockBootInstance          = "boot_instance"
+	MockCreateFirewall        = "create_firewall"
+	MockCreateInstance        = "create_instance"
+	MockCreateInstanceConfig  = "create_instance_config"
+	MockCreateInstanceDisk    = "create_instance_disk"
+	MockCreatePlacementGroup  = "create_placement_group"
+	MockCreateVolume          = "create_volume"
+	MockDeleteFirewall        = "delete_firewall"
+	MockDeleteInstance        = "delete_instance"
+	MockDeletePlacementGroup  = "delete_placement_group"
+	MockDeleteVolume          = "delete_volume"
+	MockGetFirewall           = "get_firewall"
+	MockGetImage              = "get_image"
+	MockGetInstance           = "get_instance"
+	MockGetInstanceDisk       = "get_instance_disk"
+	MockGetInstanceIPs        = "get_instance_ip_addresses"
+	MockGetPlacementGroup     = "get_placement_group"
+	MockGetRegion             = "get_region"
+	MockGetRegionAvailability = "get_region_availability"
+	MockGetStackscript        = "get_stackscript"
+	MockGetType               = "get_type"
+	MockGetVolume             = "get_volume"
+	MockGetVPC                = "get_vpc"
+	MockListEvents            = "list_events"
+	MockListFirewalls         = "list_firewalls"
+	MockListInstances         = "list_instances"
+	MockListInstanceVolumes   = "list_instance_volumes"
+	MockListPlacementGroups   = "list_placement_groups"
+	MockListVolumes           = "list_volumes"
+	MockListVPCs              = "list_vpcs"
+	MockShutdownInstance      = "shutdown_instance"
+	MockUpdateInstance        = "update_instance"
+	MockUpdateVolume          = "update_volume"
 )
 
 type call struct {
@@ -29,20 +79,90 @@ type call struct {
 	args any
 }
 
+// errLinodeBusy is how Linode refuses to boot or shut down an instance
+// which is busy booting, shutting down, etc.
+var errLinodeBusy = &linodego.Error{Code: 400, Message: "Linode busy."}
+
 func ptr[T any](v T) *T {
 	return &v
 }
 
 type mockLinode struct {
-	calls          []call
-	createInstance func(context.Context, linodego.InstanceCreateOptions) (*linodego.Instance, error)
-	deleteInstance func(context.Context, int) error
-	getInstance    func(context.Context, int) (*linodego.Instance, error)
-	listInstances  func(context.Context, *linodego.ListOptions) ([]linodego.Instance, error)
+	// mu guards calls, the client calling the API concurrently.
+	mu                    sync.Mutex
+	calls                 []call
+	attachVolume          func(context.Context, int, *linodego.VolumeAttachOptions) (*linodego.Volume, error)
+	bootInstance          func(context.Context, int, int) error
+	createFirewall        func(context.Context, linodego.FirewallCreateOptions) (*linodego.Firewall, error)
+	createInstance        func(context.Context, linodego.InstanceCreateOptions) (*linodego.Instance, error)
+	createInstanceConfig  func(context.Context, int, linodego.InstanceConfigCreateOptions) (*linodego.InstanceConfig, error)
+	createInstanceDisk    func(context.Context, int, linodego.InstanceDiskCreateOptions) (*linodego.InstanceDisk, error)
+	createPlacementGroup  func(context.Context, linodego.PlacementGroupCreateOptions) (*linodego.PlacementGroup, error)
+	createVolume          func(context.Context, linodego.VolumeCreateOptions) (*linodego.Volume, error)
+	deleteFirewall        func(context.Context, int) error
+	deleteInstance        func(context.Context, int) error
+	deletePlacementGroup  func(context.Context, int) error
+	deleteVolume          func(context.Context, int) error
+	getFirewall           func(context.Context, int) (*linodego.Firewall, error)
+	getImage              func(context.Context, string) (*linodego.Image, error)
+	getInstance           func(context.Context, int) (*linodego.Instance, error)
+	getInstanceDisk       func(context.Context, int, int) (*linodego.InstanceDisk, error)
+	getInstanceIPs        func(context.Context, int) (*linodego.InstanceIPAddressResponse, error)
+	getPlacementGroup     func(context.Context, int) (*linodego.PlacementGroup, error)
+	getRegion             func(context.Context, string) (*linodego.Region, error)
+	getRegionAvailability func(context.Context, string) ([]linodego.RegionAvailability, error)
+	getStackscript        func(context.Context, int) (*linodego.Stackscript, error)
+	getType               func(context.Context, string) (*linodego.LinodeType, error)
+	getVolume             func(context.Context, int) (*linodego.Volume, error)
+	getVPC                func(context.Context, int) (*linodego.VPC, error)
+	listEvents            func(context.Context, *linodego.ListOptions) ([]linodego.Event, error)
+	listFirewalls         func(context.Context, *linodego.ListOptions) ([]linodego.Firewall, error)
+	listInstances         func(context.Context, *linodego.ListOptions) ([]linodego.Instance, error)
+	listInstanceVolumes   func(context.Context, int, *linodego.ListOptions) ([]linodego.Volume, error)
+	listPlacementGroups   func(context.Context, *linodego.ListOptions) ([]linodego.PlacementGroup, error)
+	listVolumes           func(context.Context, *linodego.ListOptions) ([]linodego.Volume, error)
+	listVPCs              func(context.Context, *linodego.ListOptions) ([]linodego.VPC, error)
+	shutdownInstance      func(context.Context, int) error
+	updateInstance        func(context.Context, int, linodego.InstanceUpdateOptions) (*linodego.Instance, error)
+	updateVolume          func(context.Context, int, linodego.VolumeUpdateOptions) (*linodego.Volume, error)
+}
+
+func (m *mockLinode) record(c call) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, c)
+}
+
+func (m *mockLinode) AttachVolume(ctx context.Context, ID int, opts *linodego.VolumeAttachOptions) (*linodego.Volume, error) {
+	m.record(call{name: MockAttachVolume, args: opts})
+	if m.attachVolume != nil {
+		return m.attachVolume(ctx, ID, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) BootInstance(ctx context.Context, ID int, configID int) error {
+	m.record(call{name: MockBootInstance, args: ID})
+	if m.bootInstance != nil {
+		return m.bootInstance(ctx, ID, configID)
+	}
+
+	return nil
+}
+
+func (m *mockLinode) CreateFirewall(ctx context.Context, opts linodego.FirewallCreateOptions) (*linodego.Firewall, error) {
+	m.record(call{name: MockCreateFirewall, args: opts})
+	if m.createFirewall != nil {
+		return m.createFirewall(ctx, opts)
+	}
+
+	return nil, nil
 }
 
 func (m *mockLinode) CreateInstance(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
-	m.calls = append(m.calls, call{name: MockCreateInstance, args: opts})
+	m.record(call{name: MockCreateInstance, args: opts})
 	if m.createInstance != nil {
 		return m.createInstance(ctx, opts)
 	}
@@ -50,8 +170,53 @@ func (m *mockLinode) CreateInstance(ctx context.Context, opts linodego.InstanceC
 	return nil, nil
 }
 
+func (m *mockLinode) CreateInstanceConfig(ctx context.Context, ID int, opts linodego.InstanceConfigCreateOptions) (*linodego.InstanceConfig, error) {
+	m.record(call{name: MockCreateInstanceConfig, args: opts})
+	if m.createInstanceConfig != nil {
+		return m.createInstanceConfig(ctx, ID, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) CreateInstanceDisk(ctx context.Context, ID int, opts linodego.InstanceDiskCreateOptions) (*linodego.InstanceDisk, error) {
+	m.record(call{name: MockCreateInstanceDisk, args: opts})
+	if m.createInstanceDisk != nil {
+		return m.createInstanceDisk(ctx, ID, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) CreatePlacementGroup(ctx context.Context, opts linodego.PlacementGroupCreateOptions) (*linodego.PlacementGroup, error) {
+	m.record(call{name: MockCreatePlacementGroup, args: opts})
+	if m.createPlacementGroup != nil {
+		return m.createPlacementGroup(ctx, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) CreateVolume(ctx context.Context, opts linodego.VolumeCreateOptions) (*linodego.Volume, error) {
+	m.record(call{name: MockCreateVolume, args: opts})
+	if m.createVolume != nil {
+		return m.createVolume(ctx, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) DeleteFirewall(ctx context.Context, ID int) error {
+	m.record(call{name: MockDeleteFirewall, args: ID})
+	if m.deleteFirewall != nil {
+		return m.deleteFirewall(ctx, ID)
+	}
+
+	return nil
+}
+
 func (m *mockLinode) DeleteInstance(ctx context.Context, ID int) error {
-	m.calls = append(m.calls, call{name: MockDeleteInstance, args: ID})
+	m.record(call{name: MockDeleteInstance, args: ID})
 	if m.deleteInstance != nil {
 		return m.deleteInstance(ctx, ID)
 	}
@@ -59,8 +224,44 @@ func (m *mockLinode) DeleteInstance(ctx context.Context, ID int) error {
 	return nil
 }
 
+func (m *mockLinode) DeletePlacementGroup(ctx context.Context, ID int) error {
+	m.record(call{name: MockDeletePlacementGroup, args: ID})
+	if m.deletePlacementGroup != nil {
+		return m.deletePlacementGroup(ctx, ID)
+	}
+
+	return nil
+}
+
+func (m *mockLinode) DeleteVolume(ctx context.Context, ID int) error {
+	m.record(call{name: MockDeleteVolume, args: ID})
+	if m.deleteVolume != nil {
+		return m.deleteVolume(ctx, ID)
+	}
+
+	return nil
+}
+
+func (m *mockLinode) GetFirewall(ctx context.Context, ID int) (*linodego.Firewall, error) {
+	m.record(call{name: MockGetFirewall, args: ID})
+	if m.getFirewall != nil {
+		return m.getFirewall(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetImage(ctx context.Context, ID string) (*linodego.Image, error) {
+	m.record(call{name: MockGetImage, args: ID})
+	if m.getImage != nil {
+		return m.getImage(ctx, ID)
+	}
+
+	return nil, nil
+}
+
 func (m *mockLinode) GetInstance(ctx context.Context, ID int) (*linodego.Instance, error) {
-	m.calls = append(m.calls, call{name: MockGetInstance, args: ID})
+	m.record(call{name: MockGetInstance, args: ID})
 	if m.getInstance != nil {
 		return m.getInstance(ctx, ID)
 	}
@@ -68,8 +269,107 @@ func (m *mockLinode) GetInstance(ctx context.Context, ID int) (*linodego.Instanc
 	return nil, nil
 }
 
+func (m *mockLinode) GetInstanceDisk(ctx context.Context, ID int, diskID int) (*linodego.InstanceDisk, error) {
+	m.record(call{name: MockGetInstanceDisk, args: diskID})
+	if m.getInstanceDisk != nil {
+		return m.getInstanceDisk(ctx, ID, diskID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetInstanceIPAddresses(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+	m.record(call{name: MockGetInstanceIPs, args: ID})
+	if m.getInstanceIPs != nil {
+		return m.getInstanceIPs(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetPlacementGroup(ctx context.Context, ID int) (*linodego.PlacementGroup, error) {
+	m.record(call{name: MockGetPlacementGroup, args: ID})
+	if m.getPlacementGroup != nil {
+		return m.getPlacementGroup(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetRegion(ctx context.Context, ID string) (*linodego.Region, error) {
+	m.record(call{name: MockGetRegion, args: ID})
+	if m.getRegion != nil {
+		return m.getRegion(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetRegionAvailability(ctx context.Context, ID string) ([]linodego.RegionAvailability, error) {
+	m.record(call{name: MockGetRegionAvailability, args: ID})
+	if m.getRegionAvailability != nil {
+		return m.getRegionAvailability(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetStackscript(ctx context.Context, ID int) (*linodego.Stackscript, error) {
+	m.record(call{name: MockGetStackscript, args: ID})
+	if m.getStackscript != nil {
+		return m.getStackscript(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetType(ctx context.Context, ID string) (*linodego.LinodeType, error) {
+	m.record(call{name: MockGetType, args: ID})
+	if m.getType != nil {
+		return m.getType(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetVolume(ctx context.Context, ID int) (*linodego.Volume, error) {
+	m.record(call{name: MockGetVolume, args: ID})
+	if m.getVolume != nil {
+		return m.getVolume(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) GetVPC(ctx context.Context, ID int) (*linodego.VPC, error) {
+	m.record(call{name: MockGetVPC, args: ID})
+	if m.getVPC != nil {
+		return m.getVPC(ctx, ID)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) ListEvents(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+	m.record(call{name: MockListEvents, args: opts})
+	if m.listEvents != nil {
+		return m.listEvents(ctx, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) ListFirewalls(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Firewall, error) {
+	m.record(call{name: MockListFirewalls, args: opts})
+	if m.listFirewalls != nil {
+		return m.listFirewalls(ctx, opts)
+	}
+
+	return nil, nil
+}
+
 func (m *mockLinode) ListInstances(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-	m.calls = append(m.calls, call{name: MockListInstances, args: opts})
+	m.record(call{name: MockListInstances, args: opts})
 	if m.listInstances != nil {
 		return m.listInstances(ctx, opts)
 	}
@@ -77,6 +377,69 @@ func (m *mockLinode) ListInstances(ctx context.Context, opts *linodego.ListOptio
 	return nil, nil
 }
 
+func (m *mockLinode) ListInstanceVolumes(ctx context.Context, ID int, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+	m.record(call{name: MockListInstanceVolumes, args: ID})
+	if m.listInstanceVolumes != nil {
+		return m.listInstanceVolumes(ctx, ID, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) ListPlacementGroups(ctx context.Context, opts *linodego.ListOptions) ([]linodego.PlacementGroup, error) {
+	m.record(call{name: MockListPlacementGroups, args: opts})
+	if m.listPlacementGroups != nil {
+		return m.listPlacementGroups(ctx, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) ListVolumes(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+	m.record(call{name: MockListVolumes, args: opts})
+	if m.listVolumes != nil {
+		return m.listVolumes(ctx, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) ListVPCs(ctx context.Context, opts *linodego.ListOptions) ([]linodego.VPC, error) {
+	m.record(call{name: MockListVPCs, args: opts})
+	if m.listVPCs != nil {
+		return m.listVPCs(ctx, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) ShutdownInstance(ctx context.Context, ID int) error {
+	m.record(call{name: MockShutdownInstance, args: ID})
+	if m.shutdownInstance != nil {
+		return m.shutdownInstance(ctx, ID)
+	}
+
+	return nil
+}
+
+func (m *mockLinode) UpdateInstance(ctx context.Context, ID int, opts linodego.InstanceUpdateOptions) (*linodego.Instance, error) {
+	m.record(call{name: MockUpdateInstance, args: opts})
+	if m.updateInstance != nil {
+		return m.updateInstance(ctx, ID, opts)
+	}
+
+	return nil, nil
+}
+
+func (m *mockLinode) UpdateVolume(ctx context.Context, ID int, opts linodego.VolumeUpdateOptions) (*linodego.Volume, error) {
+	m.record(call{name: MockUpdateVolume, args: opts})
+	if m.updateVolume != nil {
+		return m.updateVolume(ctx, ID, opts)
+	}
+
+	return nil, nil
+}
+
 func TestCreateInstance(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		m := &mockLinode{
@@ -154,87 +517,312 @@ func TestCreateInstance(t *testing.T) {
 	})
 }
 
-func TestDeleteInstance(t *testing.T) {
-	t.Run("Success", func(t *testing.T) {
-		m := &mockLinode{
-			calls: []call{},
+func TestCreateInstanceCloudConfigSpec(t *testing.T) {
+	template := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho {{ .RunnerName }} {{ .ExtraContext.team }}\n"))
+	setup := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho setup\n"))
+
+	files := func(t *testing.T, format string, data []byte) map[string]string {
+		contents := map[string]string{}
+		if format == "ignition" {
+			cfg, rpt, err := ignition.Parse(data)
+			require.NoError(t, err, rpt.String())
+
+			for _, f := range cfg.Storage.Files {
+				b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*f.Contents.Source, "data:;base64,"))
+				require.NoError(t, err)
+				contents[f.Path] = string(b)
+			}
+
+			return contents
 		}
 
-		cli, err := client.New(
-			&config.Config{
-				Token: "foo",
-			},
-			m,
-			"1234",
-		)
-		require.NoError(t, err)
+		var cfg cloudconfig.CloudInit
+		require.NoError(t, yaml.Unmarshal(data, &cfg))
+		for _, f := range cfg.WriteFiles {
+			b, err := base64.StdEncoding.DecodeString(f.Content)
+			require.NoError(t, err)
+			contents[f.Path] = string(b)
+		}
 
-		err = cli.DeleteInstance(t.Context(), "9876")
-		require.NoError(t, err)
+		return contents
+	}
 
-		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockDeleteInstance)
+	tests := []struct {
+		name          string
+		format        string
+		installScript string
+		preInstall    string
+	}{
+		{
+			name:          "cloud-init",
+			format:        "cloud-init",
+			installScript: "/install_runner.sh",
+			preInstall:    "/garm-pre-install/01-setup.sh",
+		},
+		{
+			name:          "Ignition",
+			format:        "ignition",
+			installScript: "/opt/garm/install_runner.sh",
+			preInstall:    "/opt/garm/pre-install/01-setup.sh",
+		},
+	}
 
-		opts, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, opts, 9876)
-	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+			}
 
-	t.Run("Success from ID not being an ID", func(t *testing.T) {
-		m := &mockLinode{
-			calls: []call{},
-			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
-				return &linodego.Instance{
-					ID:    9876,
-					Label: "foo",
-				}, nil
-			},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return []linodego.Instance{
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
 					{
-						ID: 9876,
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
 					},
-				}, nil
+				},
+				ExtraSpecs: json.RawMessage(fmt.Sprintf(`{
+					"user_data_format": %q,
+					"runner_install_template": %q,
+					"pre_install_scripts": {"01-setup.sh": %q},
+					"extra_context": {"team": "builds"}
+				}`, tt.format, template, setup)),
+				PoolID: "test-pool",
+			})
+			require.NoError(t, err)
+
+			opts, ok := m.calls[0].args.(linodego.InstanceCreateOptions)
+			require.True(t, ok)
+			data, err := base64.StdEncoding.DecodeString(opts.Metadata.UserData)
+			require.NoError(t, err)
+
+			contents := files(t, tt.format, data)
+			assert.Equal(t, contents[tt.installScript], "#!/bin/bash\necho test-instance builds\n")
+			assert.Equal(t, contents[tt.preInstall], "#!/bin/bash\necho setup\n")
+		})
+	}
+
+	t.Run("Invalid extra specs", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			extraSpecs string
+			wantErr    string
+		}{
+			{
+				name:       "runner install template not base64",
+				extraSpecs: `{"runner_install_template": "#!/bin/bash"}`,
+				wantErr:    "runner_install_template is not valid base64",
+			},
+			{
+				name:       "pre-install script not base64",
+				extraSpecs: fmt.Sprintf(`{"pre_install_scripts": {"01-setup.sh": %q, "02-docker.sh": "echo docker"}}`, setup),
+				wantErr:    "pre_install_scripts: 02-docker.sh is not valid base64",
+			},
+			{
+				name:       "unknown key",
+				extraSpecs: `{"pre_install_script": {}}`,
+				wantErr:    `unmarshalling extra_specs: json: unknown field "pre_install_script"`,
+			},
+			{
+				name:       "unknown user data format",
+				extraSpecs: `{"user_data_format": "ignitoin"}`,
+				wantErr:    "user_data_format needs to be cloud-init or ignition",
 			},
 		}
 
-		cli, err := client.New(
-			&config.Config{
-				Token: "foo",
-			},
-			m,
-			"1234",
-		)
-		require.NoError(t, err)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				m := &mockLinode{calls: []call{}}
 
-		err = cli.DeleteInstance(t.Context(), "foo")
-		require.Nil(t, err)
+				cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+				require.NoError(t, err)
 
-		require.Len(t, m.calls, 2)
+				_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+					Name:   "test-instance",
+					OSArch: params.Amd64,
+					OSType: params.Linux,
+					Flavor: "g6-standard-2",
+					Image:  "linode/ubuntu24.04",
+					Tools: []params.RunnerApplicationDownload{
+						{
+							OS:           ptr("linux"),
+							Architecture: ptr("x64"),
+							DownloadURL:  ptr("http://test.com"),
+							Filename:     ptr("runner.tar.gz"),
+						},
+					},
+					ExtraSpecs: json.RawMessage(tt.extraSpecs),
+					PoolID:     "test-pool",
+				})
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, m.calls)
+			})
+		}
+	})
+}
 
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
+func TestCreateInstanceUserDataSize(t *testing.T) {
+	// 96 KiB of shell script compresses well.
+	large := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\n" + strings.Repeat("echo 'setting up the runner'\n", 96*1024/28)))
 
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, `{"label":"foo"}`)
+	random := make([]byte, 96*1024)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+	// Random data does not compress.
+	incompressible := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\n: " + base64.StdEncoding.EncodeToString(random) + "\n"))
 
-		c = m.calls[1]
-		assert.Equal(t, c.name, MockDeleteInstance)
+	tests := []struct {
+		name     string
+		format   string
+		script   string
+		wantErr  string
+		validate func(t *testing.T, userData []byte)
+	}{
+		{
+			name:   "cloud-init compressed",
+			format: "cloud-init",
+			script: large,
+			validate: func(t *testing.T, userData []byte) {
+				r, err := gzip.NewReader(bytes.NewReader(userData))
+				require.NoError(t, err)
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
 
-		ID, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, ID, 9876)
-	})
+				var cfg cloudconfig.CloudInit
+				require.NoError(t, yaml.Unmarshal(data, &cfg))
+				assert.Contains(t, cfg.RunCmd, "/garm-pre-install/01-setup.sh")
+			},
+		},
+		{
+			name:   "Ignition files compressed",
+			format: "ignition",
+			script: large,
+			validate: func(t *testing.T, userData []byte) {
+				cfg, rpt, err := ignition.Parse(userData)
+				require.NoError(t, err, rpt.String())
 
-	t.Run("Fail from API", func(t *testing.T) {
-		m := &mockLinode{
-			calls: []call{},
-			deleteInstance: func(ctx context.Context, ID int) error {
-				return fmt.Errorf("random error from the API")
+				for _, f := range cfg.Storage.Files {
+					assert.Equal(t, *f.Contents.Compression, "gzip", f.Path)
+				}
 			},
-		}
+		},
+		{
+			name:   "cloud-init uncompressed",
+			format: "cloud-init",
+			script: base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho setup\n")),
+			validate: func(t *testing.T, userData []byte) {
+				var cfg cloudconfig.CloudInit
+				require.NoError(t, yaml.Unmarshal(userData, &cfg))
+			},
+		},
+		{
+			name:    "cloud-init too large",
+			format:  "cloud-init",
+			script:  incompressible,
+			wantErr: "user data too large",
+		},
+		{
+			name:    "Ignition too large",
+			format:  "ignition",
+			script:  incompressible,
+			wantErr: "user data too large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+			}
+
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: json.RawMessage(fmt.Sprintf(`{
+					"user_data_format": %q,
+					"pre_install_scripts": {"01-setup.sh": %q}
+				}`, tt.format, tt.script)),
+				PoolID: "test-pool",
+			})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, m.calls)
+				return
+			}
+			require.NoError(t, err)
+
+			opts, ok := m.calls[0].args.(linodego.InstanceCreateOptions)
+			require.True(t, ok)
+			assert.LessOrEqual(t, len(opts.Metadata.UserData), 64*1024)
+
+			userData, err := base64.StdEncoding.DecodeString(opts.Metadata.UserData)
+			require.NoError(t, err)
+			tt.validate(t, userData)
+		})
+	}
+}
+
+func TestCreateInstanceIgnition(t *testing.T) {
+	bootstrapParams := func(image string, extraSpecs string) params.BootstrapInstance {
+		p := testBootstrapParams(image, extraSpecs)
+		p.InstanceToken = "test-token"
+		p.SSHKeys = []string{"ssh-ed25519 AAAA test@garm"}
+		p.CACertBundle = testCACert(t)
+		p.Tools[0].SHA256Checksum = ptr("sha256:1123")
+		p.Tools[0].TempDownloadToken = ptr("test-token")
+
+		return p
+	}
+
+	t.Run("Success from Flatcar image", func(t *testing.T) {
+		m := newCreateMock()
 
 		cli, err := client.New(
 			&config.Config{
@@ -245,58 +833,3800 @@ func TestDeleteInstance(t *testing.T) {
 		)
 		require.NoError(t, err)
 
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("private/flatcar-stable", `{
+			"pre_install_scripts": {
+				"01-setup.sh": "IyEvYmluL2Jhc2gKZWNobyBzZXR1cAo="
+			}
+		}`))
+		require.NoError(t, err)
+
+		cfg, rpt, err := ignition.Parse(createUserData(t, m))
+		require.NoError(t, err, rpt.String())
+		assert.False(t, rpt.IsFatal())
+
+		require.Len(t, cfg.Passwd.Users, 1)
+		assert.Equal(t, cfg.Passwd.Users[0].Name, "runner")
+		assert.Equal(t, cfg.Passwd.Users[0].SSHAuthorizedKeys, []types.SSHAuthorizedKey{"ssh-ed25519 AAAA test@garm"})
+
+		paths := make([]string, len(cfg.Storage.Files))
+		for i, f := range cfg.Storage.Files {
+			paths[i] = f.Path
+		}
+		assert.Equal(t, paths, []string{
+			"/etc/sudoers.d/runner",
+			"/etc/ssl/certs/garm-ca.pem",
+			"/opt/garm/pre-install/01-setup.sh",
+			"/opt/garm/install_runner.sh",
+		})
+
+		require.Len(t, cfg.Systemd.Units, 1)
+		unit := cfg.Systemd.Units[0]
+		assert.Equal(t, unit.Name, "garm-runner-install.service")
+		require.NotNil(t, unit.Enabled)
+		assert.True(t, *unit.Enabled)
+		require.NotNil(t, unit.Contents)
+		assert.Contains(t, *unit.Contents, "ExecStartPre=/usr/sbin/update-ca-certificates")
+		assert.Contains(t, *unit.Contents, "ExecStartPre=/opt/garm/pre-install/01-setup.sh")
+		assert.Contains(t, *unit.Contents, "ExecStart=/usr/bin/su -l -c /opt/garm/install_runner.sh runner")
+	})
+
+	t.Run("Success from extra specs", func(t *testing.T) {
+		m := newCreateMock()
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("private/1234", `{"user_data_format": "ignition"}`))
+		require.NoError(t, err)
+
+		_, rpt, err := ignition.Parse(createUserData(t, m))
+		require.NoError(t, err, rpt.String())
+	})
+
+	t.Run("Success forcing cloud-init on a Flatcar image", func(t *testing.T) {
+		m := newCreateMock()
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("private/flatcar-stable", `{"user_data_format": "cloud-init"}`))
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(string(createUserData(t, m)), "#cloud-config"))
+	})
+
+	t.Run("Fail with extra packages", func(t *testing.T) {
+		m := newCreateMock()
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("private/flatcar-stable", `{"extra_packages": ["curl"]}`))
+		assert.ErrorContains(t, err, "generating userdata: extra_packages is not supported with ignition")
+
+		assert.Empty(t, m.calls)
+	})
+}
+
+// testBootstrapParams returns the bootstrap params of a runner of the test
+// pool.
+func testBootstrapParams(image string, extraSpecs string) params.BootstrapInstance {
+	return params.BootstrapInstance{
+		Name:   "test-instance",
+		OSArch: params.Amd64,
+		OSType: params.Linux,
+		Flavor: "g6-standard-2",
+		Image:  image,
+		Tools: []params.RunnerApplicationDownload{
+			{
+				OS:           ptr("linux"),
+				Architecture: ptr("x64"),
+				DownloadURL:  ptr("http://test.com"),
+				Filename:     ptr("runner.tar.gz"),
+			},
+		},
+		ExtraSpecs: json.RawMessage(extraSpecs),
+		PoolID:     "test-pool",
+	}
+}
+
+// newCreateMock returns a mock creating the instance 9876, running once
+// created.
+func newCreateMock() *mockLinode {
+	return &mockLinode{
+		calls: []call{},
+		createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+			return &linodego.Instance{
+				ID:     9876,
+				Label:  opts.Label,
+				Status: linodego.InstanceProvisioning,
+			}, nil
+		},
+		getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+			return &linodego.Instance{
+				ID:     ID,
+				Status: linodego.InstanceRunning,
+			}, nil
+		},
+	}
+}
+
+// createUserData returns the decoded user data of the first instance
+// created, the first API call.
+func createUserData(t *testing.T, m *mockLinode) []byte {
+	t.Helper()
+
+	require.NotEmpty(t, m.calls)
+	opts, ok := m.calls[0].args.(linodego.InstanceCreateOptions)
+	require.True(t, ok)
+	require.NotNil(t, opts.Metadata)
+
+	data, err := base64.StdEncoding.DecodeString(opts.Metadata.UserData)
+	require.NoError(t, err)
+
+	return data
+}
+
+// testCACert returns a self-signed PEM encoded certificate.
+func testCACert(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "garm-test-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCreateInstanceNetwork(t *testing.T) {
+	vpc := linodego.VPC{
+		ID:     42,
+		Label:  "runners",
+		Region: "us-ord",
+		Subnets: []linodego.VPCSubnet{
+			{
+				ID:    7,
+				Label: "default",
+			},
+			{
+				ID:    8,
+				Label: "ci",
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		network    *config.Network
+		extraSpecs string
+		want       []linodego.InstanceConfigInterfaceCreateOptions
+		wantCalls  []string
+		wantErr    string
+	}{
+		{
+			name: "no network",
+			want: nil,
+		},
+		{
+			name: "VPC by label with 1:1 NAT and a VLAN",
+			network: &config.Network{
+				VPC:     "runners",
+				Subnet:  "ci",
+				NAT1To1: true,
+				VLANs: []config.VLAN{
+					{
+						Label: "builds",
+					},
+				},
+			},
+			want: []linodego.InstanceConfigInterfaceCreateOptions{
+				{
+					Purpose:  linodego.InterfacePurposeVPC,
+					Primary:  true,
+					SubnetID: ptr(8),
+					IPv4: &linodego.VPCIPv4{
+						NAT1To1: ptr("any"),
+					},
+				},
+				{
+					Purpose: linodego.InterfacePurposeVLAN,
+					Label:   "builds",
+				},
+			},
+			wantCalls: []string{MockListVPCs},
+		},
+		{
+			name: "VPC by ID with a public interface from extra specs",
+			network: &config.Network{
+				VPC:    "runners",
+				Subnet: "ci",
+			},
+			extraSpecs: `{"network": {"vpc": "42", "subnet": "7", "public": true}}`,
+			want: []linodego.InstanceConfigInterfaceCreateOptions{
+				{
+					Purpose: linodego.InterfacePurposePublic,
+					Primary: true,
+				},
+				{
+					Purpose:  linodego.InterfacePurposeVPC,
+					SubnetID: ptr(7),
+				},
+			},
+			wantCalls: []string{MockGetVPC},
+		},
+		{
+			name: "VLAN only",
+			network: &config.Network{
+				VLANs: []config.VLAN{
+					{
+						Label: "builds",
+					},
+				},
+			},
+			want: []linodego.InstanceConfigInterfaceCreateOptions{
+				{
+					Purpose: linodego.InterfacePurposePublic,
+					Primary: true,
+				},
+				{
+					Purpose: linodego.InterfacePurposeVLAN,
+					Label:   "builds",
+				},
+			},
+		},
+		{
+			name: "unknown subnet",
+			network: &config.Network{
+				VPC:    "runners",
+				Subnet: "foo",
+			},
+			wantErr: "creating instance: us-ord: network unavailable: no subnet foo in VPC runners",
+		},
+		{
+			name: "unknown VPC",
+			network: &config.Network{
+				VPC:    "foo",
+				Subnet: "ci",
+			},
+			wantErr: "getting network interfaces: getting VPC: no VPC matching this label: foo",
+		},
+		{
+			name:       "invalid network from extra specs",
+			extraSpecs: `{"network": {"vpc": "runners"}}`,
+			wantErr:    "getting extra specs: validating network: vpc needs a subnet",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+				getVPC: func(ctx context.Context, ID int) (*linodego.VPC, error) {
+					return &vpc, nil
+				},
+				listVPCs: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.VPC, error) {
+					if opts.Filter == `{"label":"runners"}` {
+						return []linodego.VPC{vpc}, nil
+					}
+
+					return nil, nil
+				},
+			}
+
+			cli, err := client.New(
+				&config.Config{
+					Token:   "foo",
+					Region:  "us-ord",
+					Network: tt.network,
+				},
+				m,
+				"1234",
+			)
+			require.NoError(t, err)
+
+			var extraSpecs json.RawMessage
+			if tt.extraSpecs != "" {
+				extraSpecs = json.RawMessage(tt.extraSpecs)
+			}
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: extraSpecs,
+				PoolID:     "test-pool",
+			})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			calls := []string{}
+			var opts linodego.InstanceCreateOptions
+			for _, c := range m.calls {
+				if c.name == MockCreateInstance {
+					opts = c.args.(linodego.InstanceCreateOptions)
+					break
+				}
+				calls = append(calls, c.name)
+			}
+			if tt.wantCalls == nil {
+				tt.wantCalls = []string{}
+			}
+			assert.Equal(t, calls, tt.wantCalls)
+
+			assert.Equal(t, opts.Interfaces, tt.want)
+			if tt.want != nil {
+				assert.Equal(t, opts.InterfaceGeneration, linodego.GenerationLegacyConfig)
+			}
+		})
+	}
+}
+
+func TestCreateInstanceFirewall(t *testing.T) {
+	tests := []struct {
+		name            string
+		firewallID      int
+		managedFirewall bool
+		extraSpecs      string
+		firewalls       []linodego.Firewall
+		want            int
+		wantCalls       []string
+	}{
+		{
+			name:      "no firewall",
+			want:      0,
+			wantCalls: []string{},
+		},
+		{
+			name:       "firewall from config",
+			firewallID: 1111,
+			want:       1111,
+			wantCalls:  []string{},
+		},
+		{
+			name:            "firewall from extra specs",
+			firewallID:      1111,
+			managedFirewall: false,
+			extraSpecs:      `{"firewall_id": 2222}`,
+			want:            2222,
+			wantCalls:       []string{},
+		},
+		{
+			name:            "existing managed firewall",
+			managedFirewall: true,
+			firewalls: []linodego.Firewall{
+				{
+					ID:   3333,
+					Tags: []string{"garm-controller-id=1234"},
+				},
+			},
+			want:      3333,
+			wantCalls: []string{MockListFirewalls},
+		},
+		{
+			name:            "new managed firewall",
+			managedFirewall: true,
+			want:            4444,
+			wantCalls:       []string{MockListFirewalls, MockCreateFirewall},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createFirewall: func(ctx context.Context, opts linodego.FirewallCreateOptions) (*linodego.Firewall, error) {
+					assert.Equal(t, opts.Label, "garm-1234")
+					assert.Equal(t, opts.Rules.InboundPolicy, "DROP")
+					assert.Equal(t, opts.Tags, []string{fmt.Sprintf("%s=1234", client.TagController)})
+
+					return &linodego.Firewall{
+						ID: 4444,
+					}, nil
+				},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+				listFirewalls: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Firewall, error) {
+					return tt.firewalls, nil
+				},
+			}
+
+			cli, err := client.New(
+				&config.Config{
+					Token:           "foo",
+					FirewallID:      tt.firewallID,
+					ManagedFirewall: tt.managedFirewall,
+				},
+				m,
+				"1234",
+			)
+			require.NoError(t, err)
+
+			var extraSpecs json.RawMessage
+			if tt.extraSpecs != "" {
+				extraSpecs = json.RawMessage(tt.extraSpecs)
+			}
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: extraSpecs,
+				PoolID:     "test-pool",
+			})
+			require.NoError(t, err)
+
+			calls := []string{}
+			var opts linodego.InstanceCreateOptions
+			for _, c := range m.calls {
+				if c.name == MockCreateInstance {
+					opts = c.args.(linodego.InstanceCreateOptions)
+					break
+				}
+				calls = append(calls, c.name)
+			}
+			assert.Equal(t, calls, tt.wantCalls)
+			assert.Equal(t, opts.FirewallID, tt.want)
+		})
+	}
+}
+
+func TestCreateInstanceRegions(t *testing.T) {
+	capacityErr := &linodego.Error{Code: 400, Message: "Not enough capacity in this region"}
+
+	tests := []struct {
+		name        string
+		config      *config.Config
+		extraSpecs  string
+		createErrs  map[string]error
+		wantRegions []string
+		wantRegion  string
+		wantErr     string
+	}{
+		{
+			name: "region from config",
+			config: &config.Config{
+				Token:  "foo",
+				Region: "us-ord",
+			},
+			wantRegions: []string{"us-ord"},
+			wantRegion:  "us-ord",
+		},
+		{
+			name: "region from extra specs",
+			config: &config.Config{
+				Token:  "foo",
+				Region: "us-ord",
+			},
+			extraSpecs:  `{"region": "fr-par"}`,
+			wantRegions: []string{"fr-par"},
+			wantRegion:  "fr-par",
+		},
+		{
+			name: "failover on capacity errors",
+			config: &config.Config{
+				Token:   "foo",
+				Region:  "us-ord",
+				Regions: []string{"us-ord", "us-sea"},
+			},
+			extraSpecs: `{"regions": ["fr-par", "de-fra-2", "nl-ams"]}`,
+			createErrs: map[string]error{
+				"fr-par":   capacityErr,
+				"de-fra-2": &linodego.Error{Code: 503, Message: "Service unavailable"},
+			},
+			wantRegions: []string{"fr-par", "de-fra-2", "nl-ams"},
+			wantRegion:  "nl-ams",
+		},
+		{
+			name: "no failover on other errors",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", "us-sea"},
+			},
+			createErrs: map[string]error{
+				"us-ord": &linodego.Error{Code: 400, Message: "Invalid image"},
+			},
+			wantRegions: []string{"us-ord"},
+			wantErr:     "creating instance: us-ord: [400] Invalid image",
+		},
+		{
+			name: "no capacity anywhere",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", "us-sea"},
+			},
+			createErrs: map[string]error{
+				"us-ord": capacityErr,
+				"us-sea": capacityErr,
+			},
+			wantRegions: []string{"us-ord", "us-sea"},
+			wantErr:     "creating instance: us-ord: [400] Not enough capacity in this region\nus-sea: [400] Not enough capacity in this region",
+		},
+		{
+			name: "failover on a VPC in another region",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", "us-sea"},
+				Network: &config.Network{
+					VPC:    "runners",
+					Subnet: "ci",
+				},
+			},
+			wantRegions: []string{"us-sea"},
+			wantRegion:  "us-sea",
+		},
+		{
+			name: "VPC in none of the regions",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", "fr-par"},
+				Network: &config.Network{
+					VPC:    "runners",
+					Subnet: "ci",
+				},
+			},
+			wantRegions: []string{},
+			wantErr:     "creating instance: us-ord: network unavailable: VPC runners is in us-sea, not in us-ord\nfr-par: network unavailable: VPC runners is in us-sea, not in fr-par",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					if err, ok := tt.createErrs[opts.Region]; ok {
+						return nil, err
+					}
+
+					return &linodego.Instance{
+						ID:     9876,
+						Region: opts.Region,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Region: tt.wantRegion,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+				listVPCs: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.VPC, error) {
+					return []linodego.VPC{
+						{
+							ID:      42,
+							Label:   "runners",
+							Region:  "us-sea",
+							Subnets: []linodego.VPCSubnet{{ID: 8, Label: "ci"}},
+						},
+					}, nil
+				},
+			}
+
+			cli, err := client.New(tt.config, m, "1234")
+			require.NoError(t, err)
+
+			var extraSpecs json.RawMessage
+			if tt.extraSpecs != "" {
+				extraSpecs = json.RawMessage(tt.extraSpecs)
+			}
+
+			i, err := cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: extraSpecs,
+				PoolID:     "test-pool",
+			})
+
+			regions := []string{}
+			for _, c := range m.calls {
+				if c.name == MockCreateInstance {
+					regions = append(regions, c.args.(linodego.InstanceCreateOptions).Region)
+				}
+			}
+			assert.Equal(t, regions, tt.wantRegions)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, i.Region, tt.wantRegion)
+		})
+	}
+}
+
+func TestCreateInstanceRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		deleteErr   error
+		updateErr   error
+		wantErr     string
+		wantTagged  bool
+		wantDeleted bool
+	}{
+		{
+			name:        "instance deleted",
+			wantErr:     "getting instance running: context canceled (last status: booting)",
+			wantDeleted: true,
+		},
+		{
+			name:      "instance already gone",
+			deleteErr: &linodego.Error{Code: 404, Message: "Not found"},
+			wantErr:   "getting instance running: context canceled (last status: booting)",
+		},
+		{
+			name:       "instance tagged when it cannot be deleted",
+			deleteErr:  &linodego.Error{Code: 500, Message: "Delete failed"},
+			wantErr:    "getting instance running: context canceled (last status: booting)\nrolling back instance 9876 (tagged as garm-failed): deleting: [500] Delete failed",
+			wantTagged: true,
+		},
+		{
+			name:      "instance neither deleted nor tagged",
+			deleteErr: &linodego.Error{Code: 500, Message: "Delete failed"},
+			updateErr: &linodego.Error{Code: 500, Message: "Update failed"},
+			wantErr:   "getting instance running: context canceled (last status: booting)\nrolling back instance 9876: deleting: [500] Delete failed, tagging as garm-failed: [500] Update failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// account holds the instances existing on the Linode account.
+			account := map[int][]string{}
+
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					account[9876] = opts.Tags
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceProvisioning,
+					}, nil
+				},
+				deleteInstance: func(ctx context.Context, ID int) error {
+					// The rollback must not depend on the request context.
+					require.NoError(t, ctx.Err())
+
+					if tt.deleteErr != nil {
+						return tt.deleteErr
+					}
+
+					delete(account, ID)
+					return nil
+				},
+				updateInstance: func(ctx context.Context, ID int, opts linodego.InstanceUpdateOptions) (*linodego.Instance, error) {
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+
+					account[ID] = *opts.Tags
+					return &linodego.Instance{ID: ID, Tags: *opts.Tags}, nil
+				},
+			}
+
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			// The request is canceled, by GARM for instance, while
+			// waiting for the instance.
+			ctx, cancel := context.WithCancel(t.Context())
+			m.getInstance = func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				cancel()
+				return &linodego.Instance{
+					ID:     ID,
+					Status: linodego.InstanceBooting,
+				}, nil
+			}
+
+			i, err := cli.CreateInstance(ctx, params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				PoolID: "test-pool",
+			})
+			require.EqualError(t, err, tt.wantErr)
+			assert.Nil(t, i)
+
+			if tt.wantDeleted {
+				assert.Empty(t, account)
+			}
+
+			if tt.wantTagged {
+				assert.Equal(t, account[9876], []string{
+					fmt.Sprintf("%s=test-pool", client.TagPool),
+					fmt.Sprintf("%s=1234", client.TagController),
+					client.TagFailed,
+				})
+			}
+		})
+	}
+}
+
+func TestCreateInstanceWait(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         *config.Config
+		extraSpecs     string
+		booting        int
+		events         []linodego.Event
+		wantGetCalls   int
+		wantStatus     linodego.InstanceStatus
+		wantErr        string
+		wantDeletions  int
+		wantNoAPICalls bool
+	}{
+		{
+			name: "wait until running",
+			config: &config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					PollInterval: config.Duration(time.Millisecond),
+				},
+			},
+			booting:      2,
+			wantGetCalls: 3,
+			wantStatus:   linodego.InstanceRunning,
+		},
+		{
+			name: "return on provisioning from config",
+			config: &config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					Until: config.WaitUntilProvisioning,
+				},
+			},
+			wantStatus: linodego.InstanceProvisioning,
+		},
+		{
+			name: "return on provisioning from extra specs",
+			config: &config.Config{
+				Token: "foo",
+			},
+			extraSpecs: `{"wait": {"until": "provisioning"}}`,
+			wantStatus: linodego.InstanceProvisioning,
+		},
+		{
+			name: "time limit exceeded",
+			config: &config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					Timeout:      config.Duration(time.Hour),
+					PollInterval: config.Duration(time.Millisecond),
+				},
+			},
+			extraSpecs: `{"wait": {"timeout": "50ms"}}`,
+			booting:    -1,
+			events: []linodego.Event{
+				{
+					Action:  linodego.ActionLinodeBoot,
+					Status:  linodego.EventFailed,
+					Entity:  &linodego.EventEntity{ID: float64(9876), Type: linodego.EntityLinode},
+					Created: ptr(time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)),
+					Message: "Kernel panic",
+				},
+			},
+			wantErr:       "getting instance running: time limit of 50ms exceeded (last status: booting)\ninstance 9876 events:\n2026-10-17T10:00:00Z: linode_boot failed: Kernel panic",
+			wantDeletions: 1,
+		},
+		{
+			name: "negative poll interval from extra specs",
+			config: &config.Config{
+				Token: "foo",
+			},
+			extraSpecs:     `{"wait": {"poll_interval": "-1s"}}`,
+			wantErr:        "getting extra specs: validating wait: poll_interval needs to be positive",
+			wantNoAPICalls: true,
+		},
+		{
+			name: "negative timeout from extra specs",
+			config: &config.Config{
+				Token: "foo",
+			},
+			extraSpecs:     `{"wait": {"timeout": "-5m"}}`,
+			wantErr:        "getting extra specs: validating wait: timeout needs to be positive",
+			wantNoAPICalls: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getCalls := 0
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceProvisioning,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					getCalls++
+					status := linodego.InstanceRunning
+					if tt.booting < 0 || getCalls <= tt.booting {
+						status = linodego.InstanceBooting
+					}
+
+					return &linodego.Instance{
+						ID:     ID,
+						Status: status,
+					}, nil
+				},
+				listEvents: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+					return tt.events, nil
+				},
+			}
+
+			cli, err := client.New(tt.config, m, "1234")
+			require.NoError(t, err)
+
+			var extraSpecs json.RawMessage
+			if tt.extraSpecs != "" {
+				extraSpecs = json.RawMessage(tt.extraSpecs)
+			}
+
+			i, err := cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: extraSpecs,
+				PoolID:     "test-pool",
+			})
+
+			deletions := 0
+			for _, c := range m.calls {
+				if c.name == MockDeleteInstance {
+					deletions++
+				}
+			}
+			assert.Equal(t, deletions, tt.wantDeletions)
+			if tt.wantNoAPICalls {
+				assert.Empty(t, m.calls)
+			}
+
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, i.Status, tt.wantStatus)
+			assert.Equal(t, getCalls, tt.wantGetCalls)
+		})
+	}
+}
+
+func TestCreateInstanceVolume(t *testing.T) {
+	bootstrapParams := testBootstrapParams
+
+	newMock := func() *mockLinode {
+		m := newCreateMock()
+		m.createVolume = func(ctx context.Context, opts linodego.VolumeCreateOptions) (*linodego.Volume, error) {
+			return &linodego.Volume{
+				ID:       5555,
+				Label:    opts.Label,
+				LinodeID: &opts.LinodeID,
+				Status:   linodego.VolumeCreating,
+			}, nil
+		}
+
+		return m
+	}
+
+	t.Run("Success with cloud-init", func(t *testing.T) {
+		m := newMock()
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("linode/ubuntu24.04", `{
+			"volume": {"size": 100, "filesystem": "xfs", "mount_path": "/var/lib/docker"}
+		}`))
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 4)
+
+		// The instance boots once the volume is attached.
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockCreateInstance)
+		opts, ok := c.args.(linodego.InstanceCreateOptions)
+		require.True(t, ok)
+		require.NotNil(t, opts.Booted)
+		assert.False(t, *opts.Booted)
+
+		c = m.calls[1]
+		assert.Equal(t, c.name, MockCreateVolume)
+		volumeOpts, ok := c.args.(linodego.VolumeCreateOptions)
+		require.True(t, ok)
+		assert.Equal(t, volumeOpts, linodego.VolumeCreateOptions{
+			Label:    "test-instance",
+			LinodeID: 9876,
+			Size:     100,
+			Tags: []string{
+				fmt.Sprintf("%s=test-pool", client.TagPool),
+				fmt.Sprintf("%s=1234", client.TagController),
+			},
+		})
+
+		assert.Equal(t, m.calls[2].name, MockBootInstance)
+		assert.Equal(t, m.calls[2].args, 9876)
+		assert.Equal(t, m.calls[3].name, MockGetInstance)
+
+		// The volume is mounted before anything else runs.
+		var cfg cloudconfig.CloudInit
+		require.NoError(t, yaml.Unmarshal(createUserData(t, m), &cfg))
+		require.NotEmpty(t, cfg.RunCmd)
+		assert.Equal(t, cfg.RunCmd[0], "/garm-mount-volume.sh")
+		assert.Contains(t, cfg.RunCmd, "su -l -c /install_runner.sh runner")
+
+		i := slices.IndexFunc(cfg.WriteFiles, func(f cloudconfig.File) bool {
+			return f.Path == "/garm-mount-volume.sh"
+		})
+		require.GreaterOrEqual(t, i, 0)
+
+		script, err := base64.StdEncoding.DecodeString(cfg.WriteFiles[i].Content)
+		require.NoError(t, err)
+		assert.Contains(t, string(script), "for device in /dev/disk/by-id/scsi-0Linode_Volume_test-instance; do\n")
+		assert.Contains(t, string(script), `mkfs -t xfs "$device"`)
+		assert.Contains(t, string(script), `echo "$device /var/lib/docker xfs defaults,nofail 0 2" >>/etc/fstab`)
+	})
+
+	t.Run("Success with Ignition", func(t *testing.T) {
+		m := newMock()
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("linode/flatcar", `{
+			"volume": {"size": 10, "mount_path": "/var/lib/docker"}
+		}`))
+		require.NoError(t, err)
+
+		cfg, rpt, err := ignition.Parse(createUserData(t, m))
+		require.NoError(t, err, rpt.String())
+
+		require.Len(t, cfg.Storage.Filesystems, 1)
+		fs := cfg.Storage.Filesystems[0]
+		assert.Equal(t, fs.Device, "/dev/disk/by-id/scsi-0Linode_Volume_test-instance")
+		assert.Equal(t, fs.Format, ptr("ext4"))
+		assert.Equal(t, fs.WipeFilesystem, ptr(false))
+
+		require.Len(t, cfg.Systemd.Units, 2)
+		assert.Contains(t, *cfg.Systemd.Units[1].Contents, "RequiresMountsFor=/var/lib/docker")
+
+		unit := cfg.Systemd.Units[0]
+		assert.Equal(t, unit.Name, `var-lib-docker.mount`)
+		require.NotNil(t, unit.Contents)
+		assert.Contains(t, *unit.Contents, "What=/dev/disk/by-id/scsi-0Linode_Volume_test-instance\n")
+		assert.Contains(t, *unit.Contents, "Where=/var/lib/docker\n")
+		assert.Contains(t, *unit.Contents, "Type=ext4\n")
+	})
+
+	t.Run("Success with a long runner name", func(t *testing.T) {
+		m := newMock()
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		bootstrap := bootstrapParams("linode/ubuntu24.04", `{"volume": {"size": 10, "mount_path": "/cache"}}`)
+		bootstrap.Name = "my-organization-pool-runner-abcdefghijkl"
+		_, err = cli.CreateInstance(t.Context(), bootstrap)
+		require.NoError(t, err)
+
+		require.Greater(t, len(m.calls), 1)
+		volumeOpts, ok := m.calls[1].args.(linodego.VolumeCreateOptions)
+		require.True(t, ok)
+		assert.Len(t, volumeOpts.Label, 32)
+		assert.True(t, strings.HasPrefix(volumeOpts.Label, "garm-"))
+	})
+
+	t.Run("Rollback of the volume", func(t *testing.T) {
+		m := newMock()
+		m.bootInstance = func(ctx context.Context, ID int, configID int) error {
+			return &linodego.Error{Code: 500, Message: "Boot failed"}
+		}
+		// The volume is detached once the instance is deleted.
+		m.getVolume = func(ctx context.Context, ID int) (*linodego.Volume, error) {
+			return &linodego.Volume{ID: ID}, nil
+		}
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("linode/ubuntu24.04", `{
+			"volume": {"size": 10, "mount_path": "/cache"}
+		}`))
+		require.EqualError(t, err, "booting instance from Linode API: [500] Boot failed")
+
+		names := make([]string, len(m.calls))
+		for i, c := range m.calls {
+			names[i] = c.name
+		}
+		assert.Equal(t, names, []string{
+			MockCreateInstance,
+			MockCreateVolume,
+			MockBootInstance,
+			MockListEvents,
+			MockDeleteInstance,
+			MockGetVolume,
+			MockDeleteVolume,
+		})
+		assert.Equal(t, m.calls[6].args, 5555)
+	})
+
+	t.Run("Fail with an invalid volume", func(t *testing.T) {
+		m := newMock()
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		_, err = cli.CreateInstance(t.Context(), bootstrapParams("linode/ubuntu24.04", `{
+			"volume": {"size": 10, "mount_path": "/var/../cache"}
+		}`))
+		require.EqualError(t, err, `getting extra specs: validating volume: volume mount path "/var/../cache" must be a clean absolute path`)
+		assert.Empty(t, m.calls)
+	})
+}
+
+func TestCreateInstanceCacheVolume(t *testing.T) {
+	const (
+		poolID = "test-pool"
+		// prefix is the label prefix of the cache volumes of the pool.
+		prefix = "garm-cache-2bee061e64ba9ae6-"
+	)
+
+	tags := func(extra ...string) []string {
+		return append([]string{client.TagCache, "garm-pool-id=test-pool", "garm-controller-id=1234"}, extra...)
+	}
+
+	tests := []struct {
+		name    string
+		count   int
+		volumes []linodego.Volume
+		// instances are the instances existing on the account, besides
+		// the one being created.
+		instances []int
+		// overwrite is the instance whose concurrent claim overwrites
+		// the one of the runner.
+		overwrite   int
+		wantClaimed int
+		wantCreated string
+	}{
+		{
+			name:  "free volume claimed",
+			count: 3,
+			volumes: []linodego.Volume{
+				{ID: 100, Label: prefix + "0", Region: "us-ord", LinodeID: ptr(1111), Tags: tags("garm-claimed-by=1111")},
+				{ID: 101, Label: prefix + "1", Region: "us-ord", Tags: tags()},
+				{ID: 102, Label: prefix + "2", Region: "us-sea", Tags: tags()},
+			},
+			instances:   []int{1111},
+			wantClaimed: 101,
+		},
+		{
+			name:  "volume of a deleted instance claimed",
+			count: 1,
+			volumes: []linodego.Volume{
+				{ID: 100, Label: prefix + "0", Region: "us-ord", Tags: tags("garm-claimed-by=4444")},
+			},
+			wantClaimed: 100,
+		},
+		{
+			name:  "volume created when none is free",
+			count: 3,
+			volumes: []linodego.Volume{
+				{ID: 100, Label: prefix + "0", Region: "us-ord", LinodeID: ptr(1111), Tags: tags("garm-claimed-by=1111")},
+				// Claimed by an instance being created.
+				{ID: 102, Label: prefix + "2", Region: "us-ord", Tags: tags("garm-claimed-by=2222")},
+			},
+			instances:   []int{1111, 2222},
+			wantCreated: prefix + "1",
+		},
+		{
+			name:  "no volume when all are in use",
+			count: 1,
+			volumes: []linodego.Volume{
+				{ID: 100, Label: prefix + "0", Region: "us-ord", LinodeID: ptr(1111), Tags: tags("garm-claimed-by=1111")},
+			},
+			instances: []int{1111},
+		},
+		{
+			name:  "no volume when losing the claim",
+			count: 1,
+			volumes: []linodego.Volume{
+				{ID: 100, Label: prefix + "0", Region: "us-ord", Tags: tags()},
+			},
+			instances: []int{5555},
+			overwrite: 5555,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// volumes holds the volumes existing on the Linode account.
+			volumes := map[int]*linodego.Volume{}
+			for _, v := range tt.volumes {
+				volumes[v.ID] = &v
+			}
+
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Label:  opts.Label,
+						Region: opts.Region,
+						Status: linodego.InstanceOffline,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					if ID != 9876 && !slices.Contains(tt.instances, ID) {
+						return nil, &linodego.Error{Code: 404, Message: "Not found"}
+					}
+
+					return &linodego.Instance{ID: ID, Status: linodego.InstanceRunning}, nil
+				},
+				listVolumes: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					var list []linodego.Volume
+					for _, v := range volumes {
+						list = append(list, *v)
+					}
+
+					return list, nil
+				},
+				getVolume: func(ctx context.Context, ID int) (*linodego.Volume, error) {
+					v := *volumes[ID]
+					return &v, nil
+				},
+				updateVolume: func(ctx context.Context, ID int, opts linodego.VolumeUpdateOptions) (*linodego.Volume, error) {
+					volumes[ID].Tags = *opts.Tags
+					if tt.overwrite != 0 {
+						volumes[ID].Tags = tags(fmt.Sprintf("garm-claimed-by=%d", tt.overwrite))
+					}
+
+					v := *volumes[ID]
+					return &v, nil
+				},
+				attachVolume: func(ctx context.Context, ID int, opts *linodego.VolumeAttachOptions) (*linodego.Volume, error) {
+					volumes[ID].LinodeID = &opts.LinodeID
+
+					v := *volumes[ID]
+					return &v, nil
+				},
+				createVolume: func(ctx context.Context, opts linodego.VolumeCreateOptions) (*linodego.Volume, error) {
+					return &linodego.Volume{ID: 103, Label: opts.Label, LinodeID: &opts.LinodeID, Tags: opts.Tags}, nil
+				},
+			}
+
+			cli, err := client.New(
+				&config.Config{
+					Token:  "foo",
+					Region: "us-ord",
+					Wait: &config.Wait{
+						PollInterval: config.Duration(time.Millisecond),
+					},
+				},
+				m,
+				"1234",
+			)
+			require.NoError(t, err)
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: json.RawMessage(fmt.Sprintf(`{
+					"cache_volume": {"count": %d, "size": 50, "mount_path": "/cache"}
+				}`, tt.count)),
+				PoolID: poolID,
+			})
+			require.NoError(t, err)
+
+			// The instance boots with or without cache volume.
+			opts, ok := m.calls[0].args.(linodego.InstanceCreateOptions)
+			require.True(t, ok)
+			require.NotNil(t, opts.Booted)
+			assert.False(t, *opts.Booted)
+			assert.True(t, slices.ContainsFunc(m.calls, func(c call) bool {
+				return c.name == MockBootInstance
+			}))
+
+			if tt.wantClaimed != 0 {
+				assert.Equal(t, volumes[tt.wantClaimed].LinodeID, ptr(9876))
+				assert.Equal(t, volumes[tt.wantClaimed].Tags, tags("garm-claimed-by=9876"))
+			}
+
+			var attached []int
+			for id, v := range volumes {
+				if v.LinodeID != nil && *v.LinodeID == 9876 {
+					attached = append(attached, id)
+				}
+			}
+			if tt.wantClaimed != 0 {
+				assert.Equal(t, attached, []int{tt.wantClaimed})
+			} else {
+				assert.Empty(t, attached)
+			}
+
+			i := slices.IndexFunc(m.calls, func(c call) bool {
+				return c.name == MockCreateVolume
+			})
+			if tt.wantCreated == "" {
+				assert.Equal(t, i, -1)
+				return
+			}
+
+			require.GreaterOrEqual(t, i, 0)
+			assert.Equal(t, m.calls[i].args, linodego.VolumeCreateOptions{
+				Label:    tt.wantCreated,
+				LinodeID: 9876,
+				Size:     50,
+				Tags:     tags("garm-claimed-by=9876"),
+			})
+		})
+	}
+
+	t.Run("Mount script", func(t *testing.T) {
+		spec := `{"cache_volume": {"count": 2, "size": 50, "mount_path": "/cache"}}`
+		script := "for device in /dev/disk/by-id/scsi-0Linode_Volume_" + prefix + "*; do"
+
+		m := &mockLinode{
+			calls: []call{},
+			createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+				return &linodego.Instance{ID: 9876, Label: opts.Label, Region: opts.Region}, nil
+			},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{ID: ID, Status: linodego.InstanceRunning}, nil
+			},
+		}
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		bootstrap := params.BootstrapInstance{
+			Name:   "test-instance",
+			OSArch: params.Amd64,
+			OSType: params.Linux,
+			Flavor: "g6-standard-2",
+			Image:  "linode/ubuntu24.04",
+			Tools: []params.RunnerApplicationDownload{
+				{
+					OS:           ptr("linux"),
+					Architecture: ptr("x64"),
+					DownloadURL:  ptr("http://test.com"),
+					Filename:     ptr("runner.tar.gz"),
+				},
+			},
+			ExtraSpecs: json.RawMessage(spec),
+			PoolID:     poolID,
+		}
+
+		_, err = cli.CreateInstance(t.Context(), bootstrap)
+		require.NoError(t, err)
+
+		opts, ok := m.calls[0].args.(linodego.InstanceCreateOptions)
+		require.True(t, ok)
+		data, err := base64.StdEncoding.DecodeString(opts.Metadata.UserData)
+		require.NoError(t, err)
+
+		var cloudInit cloudconfig.CloudInit
+		require.NoError(t, yaml.Unmarshal(data, &cloudInit))
+		require.NotEmpty(t, cloudInit.RunCmd)
+		assert.Equal(t, cloudInit.RunCmd[0], "/garm-mount-cache-volume.sh")
+
+		i := slices.IndexFunc(cloudInit.WriteFiles, func(f cloudconfig.File) bool {
+			return f.Path == "/garm-mount-cache-volume.sh"
+		})
+		require.GreaterOrEqual(t, i, 0)
+		contents, err := base64.StdEncoding.DecodeString(cloudInit.WriteFiles[i].Content)
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), script)
+		assert.Contains(t, string(contents), "no volume to mount on /cache")
+
+		m.calls = []call{}
+		bootstrap.Image = "linode/flatcar"
+		_, err = cli.CreateInstance(t.Context(), bootstrap)
+		require.NoError(t, err)
+
+		opts, ok = m.calls[0].args.(linodego.InstanceCreateOptions)
+		require.True(t, ok)
+		data, err = base64.StdEncoding.DecodeString(opts.Metadata.UserData)
+		require.NoError(t, err)
+
+		ignitionCfg, rpt, err := ignition.Parse(data)
+		require.NoError(t, err, rpt.String())
+		require.Len(t, ignitionCfg.Systemd.Units, 1)
+		assert.Contains(t, *ignitionCfg.Systemd.Units[0].Contents, "ExecStartPre=/opt/garm/mount-cache-volume.sh\n")
+
+		i = slices.IndexFunc(ignitionCfg.Storage.Files, func(f types.File) bool {
+			return f.Path == "/opt/garm/mount-cache-volume.sh"
+		})
+		require.GreaterOrEqual(t, i, 0)
+		source := *ignitionCfg.Storage.Files[i].Contents.Source
+		contents, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(source, "data:;base64,"))
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), script)
+	})
+}
+
+func TestCreateInstancePlacementGroup(t *testing.T) {
+	label := "garm-03ac6742-2bee061e64ba9ae6-us-ord"
+	groupErr := &linodego.Error{Code: 400, Message: "Placement Group cannot accept this Linode"}
+	members := func(n int) []linodego.PlacementGroupMember {
+		m := make([]linodego.PlacementGroupMember, n)
+		for i := range m {
+			m[i].LinodeID = 1000 + i
+		}
+		return m
+	}
+
+	tests := []struct {
+		name       string
+		config     *config.Config
+		extraSpecs string
+		groups     []linodego.PlacementGroup
+		members    int
+		createErrs map[string]error
+		wantCalls  []string
+		wantCreate *linodego.PlacementGroupCreateOptions
+		wantGroups []int
+		wantErr    string
+	}{
+		{
+			name: "no placement group",
+			config: &config.Config{
+				Token:  "foo",
+				Region: "us-ord",
+			},
+			wantCalls:  []string{},
+			wantGroups: []int{0},
+		},
+		{
+			name: "existing placement group",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-ord",
+				PlacementGroup: &config.PlacementGroup{ID: 4242},
+			},
+			wantCalls:  []string{MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{4242},
+		},
+		{
+			name: "placement group from extra specs",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-ord",
+				PlacementGroup: &config.PlacementGroup{},
+			},
+			extraSpecs: `{"placement_group": {"id": 4242}}`,
+			wantCalls:  []string{MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{4242},
+		},
+		{
+			name: "existing managed placement group",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-ord",
+				PlacementGroup: &config.PlacementGroup{},
+			},
+			groups: []linodego.PlacementGroup{
+				// Loosely matched by the API.
+				{ID: 5555, Label: label + "-2", Region: "us-ord"},
+				{ID: 4242, Label: label, Region: "us-ord"},
+			},
+			wantCalls:  []string{MockListPlacementGroups, MockGetRegion},
+			wantGroups: []int{4242},
+		},
+		{
+			name: "new managed placement group",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-ord",
+				PlacementGroup: &config.PlacementGroup{Policy: config.PlacementGroupPolicyFlexible},
+			},
+			wantCalls: []string{MockListPlacementGroups, MockCreatePlacementGroup, MockGetRegion},
+			wantCreate: &linodego.PlacementGroupCreateOptions{
+				Label:                label,
+				Region:               "us-ord",
+				PlacementGroupType:   linodego.PlacementGroupTypeAntiAffinityLocal,
+				PlacementGroupPolicy: linodego.PlacementGroupPolicyFlexible,
+			},
+			wantGroups: []int{4242},
+		},
+		{
+			name: "ungrouped when full",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-ord",
+				PlacementGroup: &config.PlacementGroup{ID: 4242},
+			},
+			members:    5,
+			wantCalls:  []string{MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{0},
+		},
+		{
+			name: "ungrouped in another region",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-sea",
+				PlacementGroup: &config.PlacementGroup{ID: 4242},
+			},
+			wantCalls:  []string{MockGetPlacementGroup},
+			wantGroups: []int{0},
+		},
+		{
+			name: "ungrouped when rejected",
+			config: &config.Config{
+				Token:          "foo",
+				Region:         "us-ord",
+				PlacementGroup: &config.PlacementGroup{ID: 4242},
+			},
+			createErrs: map[string]error{"us-ord": groupErr},
+			wantCalls:  []string{MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{4242, 0},
+		},
+		{
+			name: "fail when full",
+			config: &config.Config{
+				Token:  "foo",
+				Region: "us-ord",
+				PlacementGroup: &config.PlacementGroup{
+					ID:       4242,
+					Fallback: config.PlacementGroupFallbackFail,
+				},
+			},
+			members:    5,
+			wantCalls:  []string{MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{},
+			wantErr:    "creating instance: us-ord: placement group unavailable: 4242 is full (5 instances)",
+		},
+		{
+			name: "fail when rejected",
+			config: &config.Config{
+				Token:  "foo",
+				Region: "us-ord",
+				PlacementGroup: &config.PlacementGroup{
+					ID:       4242,
+					Fallback: config.PlacementGroupFallbackFail,
+				},
+			},
+			createErrs: map[string]error{"us-ord": groupErr},
+			wantCalls:  []string{MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{4242},
+			wantErr:    "creating instance: us-ord: [400] Placement Group cannot accept this Linode",
+		},
+		{
+			name: "next region when full",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-sea", "us-ord"},
+				PlacementGroup: &config.PlacementGroup{
+					ID:       4242,
+					Fallback: config.PlacementGroupFallbackFail,
+				},
+			},
+			wantCalls:  []string{MockGetPlacementGroup, MockGetPlacementGroup, MockGetRegion},
+			wantGroups: []int{4242},
+		},
+		{
+			name: "invalid placement group",
+			config: &config.Config{
+				Token:  "foo",
+				Region: "us-ord",
+			},
+			extraSpecs: `{"placement_group": {"id": 4242, "policy": "strict"}}`,
+			wantCalls:  []string{},
+			wantGroups: []int{},
+			wantErr:    "validating placement group: policy only applies to managed placement groups",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					if err, ok := tt.createErrs[opts.Region]; ok && opts.PlacementGroup != nil {
+						return nil, err
+					}
+
+					return &linodego.Instance{
+						ID:     9876,
+						Region: opts.Region,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				createPlacementGroup: func(ctx context.Context, opts linodego.PlacementGroupCreateOptions) (*linodego.PlacementGroup, error) {
+					assert.Equal(t, &opts, tt.wantCreate)
+
+					return &linodego.PlacementGroup{
+						ID:     4242,
+						Label:  opts.Label,
+						Region: opts.Region,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+				getPlacementGroup: func(ctx context.Context, ID int) (*linodego.PlacementGroup, error) {
+					return &linodego.PlacementGroup{
+						ID:      ID,
+						Region:  "us-ord",
+						Members: members(tt.members),
+					}, nil
+				},
+				getRegion: func(ctx context.Context, ID string) (*linodego.Region, error) {
+					return &linodego.Region{
+						ID: ID,
+						PlacementGroupLimits: &linodego.RegionPlacementGroupLimits{
+							MaximumLinodesPerPG: 5,
+						},
+					}, nil
+				},
+				listPlacementGroups: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.PlacementGroup, error) {
+					assert.Equal(t, opts.Filter, fmt.Sprintf(`{"label":%q}`, label))
+
+					return tt.groups, nil
+				},
+			}
+
+			cli, err := client.New(tt.config, m, "1234")
+			require.NoError(t, err)
+
+			var extraSpecs json.RawMessage
+			if tt.extraSpecs != "" {
+				extraSpecs = json.RawMessage(tt.extraSpecs)
+			}
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: extraSpecs,
+				PoolID:     "test-pool",
+			})
+
+			calls := []string{}
+			groups := []int{}
+			for _, c := range m.calls {
+				switch c.name {
+				case MockCreateInstance:
+					opts := c.args.(linodego.InstanceCreateOptions)
+					if opts.PlacementGroup == nil {
+						groups = append(groups, 0)
+					} else {
+						groups = append(groups, opts.PlacementGroup.ID)
+					}
+				case MockGetInstance, MockListEvents:
+				default:
+					calls = append(calls, c.name)
+				}
+			}
+			assert.Equal(t, calls, tt.wantCalls)
+			assert.Equal(t, groups, tt.wantGroups)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCreateInstanceManagedPlacementGroup(t *testing.T) {
+	capacityErr := &linodego.Error{Code: 400, Message: "Not enough capacity in this region"}
+	notFoundErr := &linodego.Error{Code: 404, Message: "Not found"}
+
+	tests := []struct {
+		name        string
+		regions     []string
+		createErrs  map[string][]error
+		wantGroups  []int
+		wantDeleted []int
+		wantErr     string
+	}{
+		{
+			name:    "group deleted in the meantime",
+			regions: []string{"us-ord"},
+			createErrs: map[string][]error{
+				"us-ord": {notFoundErr},
+			},
+			wantGroups:  []int{4000, 4001},
+			wantDeleted: []int{},
+		},
+		{
+			name:    "groups of the regions failed over",
+			regions: []string{"us-sea", "us-ord"},
+			createErrs: map[string][]error{
+				"us-sea": {capacityErr},
+			},
+			wantGroups:  []int{4000, 4001},
+			wantDeleted: []int{4000},
+		},
+		{
+			name:    "groups of all the regions",
+			regions: []string{"us-sea", "us-ord"},
+			createErrs: map[string][]error{
+				"us-sea": {capacityErr},
+				"us-ord": {capacityErr},
+			},
+			wantGroups:  []int{4000, 4001},
+			wantDeleted: []int{4000, 4001},
+			wantErr:     "creating instance: us-sea: [400] Not enough capacity in this region\nus-ord: [400] Not enough capacity in this region",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The groups are deleted along with their last runner, a new
+			// one is created every time the group is resolved.
+			nextGroup := 4000
+			groups := map[int]*linodego.PlacementGroup{}
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					if errs := tt.createErrs[opts.Region]; len(errs) > 0 {
+						tt.createErrs[opts.Region] = errs[1:]
+						if linodego.IsNotFound(errs[0]) {
+							delete(groups, opts.PlacementGroup.ID)
+						}
+						return nil, errs[0]
+					}
+
+					return &linodego.Instance{
+						ID:     9876,
+						Region: opts.Region,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				createPlacementGroup: func(ctx context.Context, opts linodego.PlacementGroupCreateOptions) (*linodego.PlacementGroup, error) {
+					group := &linodego.PlacementGroup{
+						ID:     nextGroup,
+						Label:  opts.Label,
+						Region: opts.Region,
+					}
+					groups[group.ID] = group
+					nextGroup++
+					return group, nil
+				},
+				deletePlacementGroup: func(ctx context.Context, ID int) error {
+					delete(groups, ID)
+					return nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+				getPlacementGroup: func(ctx context.Context, ID int) (*linodego.PlacementGroup, error) {
+					group, ok := groups[ID]
+					if !ok {
+						return nil, notFoundErr
+					}
+					return group, nil
+				},
+				getRegion: func(ctx context.Context, ID string) (*linodego.Region, error) {
+					return &linodego.Region{ID: ID}, nil
+				},
+				listPlacementGroups: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.PlacementGroup, error) {
+					return nil, nil
+				},
+			}
+
+			cli, err := client.New(&config.Config{
+				Token:          "foo",
+				Regions:        tt.regions,
+				PlacementGroup: &config.PlacementGroup{},
+			}, m, "1234")
+			require.NoError(t, err)
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				PoolID: "test-pool",
+			})
+
+			created := []int{}
+			deleted := []int{}
+			for _, c := range m.calls {
+				switch c.name {
+				case MockCreateInstance:
+					created = append(created, c.args.(linodego.InstanceCreateOptions).PlacementGroup.ID)
+				case MockDeletePlacementGroup:
+					deleted = append(deleted, c.args.(int))
+				}
+			}
+			assert.Equal(t, created, tt.wantGroups)
+			assert.Equal(t, deleted, tt.wantDeleted)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCreateInstanceStackScript(t *testing.T) {
+	script := &linodego.Stackscript{
+		ID:     4242,
+		Images: []string{"linode/ubuntu24.04", "linode/debian12"},
+		UserDefinedFields: &[]linodego.StackscriptUDF{
+			{Name: "docker_version"},
+			{Name: "log_level", Default: "info"},
+			{Name: "garm_runner_name"},
+			{Name: "garm_pool_id"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		image      string
+		extraSpecs string
+		wantScript int
+		wantData   map[string]string
+		wantErr    string
+	}{
+		{
+			name:  "public image",
+			image: "linode/ubuntu24.04",
+		},
+		{
+			name:  "private image",
+			image: "private/1234",
+		},
+		{
+			name:       "StackScript",
+			image:      "linode/ubuntu24.04",
+			extraSpecs: `{"stackscript_id": 4242, "stackscript_data": {"docker_version": "27.3"}}`,
+			wantScript: 4242,
+			wantData: map[string]string{
+				"docker_version":   "27.3",
+				"garm_runner_name": "test-instance",
+				"garm_pool_id":     "test-pool",
+			},
+		},
+		{
+			name:       "StackScript with a private image",
+			image:      "private/1234",
+			extraSpecs: `{"stackscript_id": 4242, "stackscript_data": {"docker_version": "27.3", "log_level": "debug"}}`,
+			wantScript: 4242,
+			wantData: map[string]string{
+				"docker_version":   "27.3",
+				"log_level":        "debug",
+				"garm_runner_name": "test-instance",
+				"garm_pool_id":     "test-pool",
+			},
+		},
+		{
+			name:       "StackScript not supporting the image",
+			image:      "linode/fedora41",
+			extraSpecs: `{"stackscript_id": 4242, "stackscript_data": {"docker_version": "27.3"}}`,
+			wantErr:    "getting StackScript: StackScript 4242 does not support image linode/fedora41",
+		},
+		{
+			name:       "StackScript does not exist",
+			image:      "linode/ubuntu24.04",
+			extraSpecs: `{"stackscript_id": 1111}`,
+			wantErr:    "getting StackScript: StackScript 1111 does not exist",
+		},
+		{
+			name:       "missing required field",
+			image:      "linode/ubuntu24.04",
+			extraSpecs: `{"stackscript_id": 4242}`,
+			wantErr:    "stackscript_data: docker_version is required by StackScript 4242",
+		},
+		{
+			name:       "unknown field",
+			image:      "linode/ubuntu24.04",
+			extraSpecs: `{"stackscript_id": 4242, "stackscript_data": {"docker_version": "27.3", "foo": "bar"}}`,
+			wantErr:    "stackscript_data: foo is not a field of StackScript 4242",
+		},
+		{
+			name:       "field set by the provider",
+			image:      "linode/ubuntu24.04",
+			extraSpecs: `{"stackscript_id": 4242, "stackscript_data": {"docker_version": "27.3", "garm_pool_id": "other"}}`,
+			wantErr:    "stackscript_data: garm_pool_id is set by the provider",
+		},
+		{
+			name:       "data without StackScript",
+			image:      "linode/ubuntu24.04",
+			extraSpecs: `{"stackscript_data": {"docker_version": "27.3"}}`,
+			wantErr:    "stackscript_data needs a stackscript_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceBooting,
+					}, nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+				getStackscript: func(ctx context.Context, ID int) (*linodego.Stackscript, error) {
+					if ID != script.ID {
+						return nil, &linodego.Error{Code: 404, Message: "Not found"}
+					}
+
+					return script, nil
+				},
+			}
+
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			var extraSpecs json.RawMessage
+			if tt.extraSpecs != "" {
+				extraSpecs = json.RawMessage(tt.extraSpecs)
+			}
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  tt.image,
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: extraSpecs,
+				PoolID:     "test-pool",
+			})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.False(t, slices.ContainsFunc(m.calls, func(c call) bool { return c.name == MockCreateInstance }))
+				return
+			}
+			require.NoError(t, err)
+
+			i := slices.IndexFunc(m.calls, func(c call) bool { return c.name == MockCreateInstance })
+			require.GreaterOrEqual(t, i, 0)
+			opts := m.calls[i].args.(linodego.InstanceCreateOptions)
+			assert.Equal(t, opts.Image, tt.image)
+			assert.Equal(t, opts.StackScriptID, tt.wantScript)
+			assert.Equal(t, opts.StackScriptData, tt.wantData)
+			assert.NotEmpty(t, opts.Metadata.UserData)
+		})
+	}
+}
+
+func TestCreateInstanceDisks(t *testing.T) {
+	tests := []struct {
+		name          string
+		extraSpecs    string
+		image         string
+		wantDisks     []linodego.InstanceDiskCreateOptions
+		wantDevices   linodego.InstanceConfigDeviceMap
+		wantKernel    string
+		validate      func(t *testing.T, userData []byte)
+		wantErr       string
+		wantAPICalls  []string
+		createDiskErr error
+		diskNotReady  bool
+	}{
+		{
+			name:       "Root, swap and scratch disks",
+			extraSpecs: `{"disks": {"root_size": 20480, "scratch": {"size": 40960, "mount_path": "/var/lib/docker"}}}`,
+			image:      "linode/ubuntu24.04",
+			wantDisks: []linodego.InstanceDiskCreateOptions{
+				{Label: "root", Size: 20480, Image: "linode/ubuntu24.04"},
+				{Label: "swap", Size: 512, Filesystem: "swap"},
+				{Label: "scratch", Size: 40960, Filesystem: "ext4"},
+			},
+			wantDevices: linodego.InstanceConfigDeviceMap{
+				SDA: &linodego.InstanceConfigDevice{DiskID: 1},
+				SDB: &linodego.InstanceConfigDevice{DiskID: 2},
+				SDC: &linodego.InstanceConfigDevice{DiskID: 3},
+			},
+			wantKernel: "linode/grub2",
+			validate: func(t *testing.T, userData []byte) {
+				var cfg cloudconfig.CloudInit
+				require.NoError(t, yaml.Unmarshal(userData, &cfg))
+
+				i := slices.IndexFunc(cfg.WriteFiles, func(f cloudconfig.File) bool {
+					return f.Path == "/garm-mount-scratch-disk.sh"
+				})
+				require.NotEqual(t, i, -1)
+				script, err := base64.StdEncoding.DecodeString(cfg.WriteFiles[i].Content)
+				require.NoError(t, err)
+				assert.Contains(t, string(script), "for device in /dev/sdc; do")
+				assert.Contains(t, string(script), "mount /var/lib/docker")
+				assert.Equal(t, cfg.RunCmd[0], "/garm-mount-scratch-disk.sh")
+			},
+		},
+		{
+			name:       "Root disk taking the rest of the plan disk without swap",
+			extraSpecs: `{"disks": {"swap_size": 0, "scratch": {"size": 40960, "mount_path": "/var/lib/docker"}}}`,
+			image:      "linode/ubuntu24.04",
+			wantDisks: []linodego.InstanceDiskCreateOptions{
+				{Label: "root", Size: 40960, Image: "linode/ubuntu24.04"},
+				{Label: "scratch", Size: 40960, Filesystem: "ext4"},
+			},
+			wantDevices: linodego.InstanceConfigDeviceMap{
+				SDA: &linodego.InstanceConfigDevice{DiskID: 1},
+				SDC: &linodego.InstanceConfigDevice{DiskID: 2},
+			},
+			wantKernel: "linode/grub2",
+		},
+		{
+			name:       "Custom kernel",
+			extraSpecs: `{"disks": {"root_size": 20480, "swap_size": 0, "kernel": "linode/latest-64bit"}}`,
+			image:      "linode/flatcar",
+			wantDisks: []linodego.InstanceDiskCreateOptions{
+				{Label: "root", Size: 20480, Image: "linode/flatcar"},
+			},
+			wantDevices: linodego.InstanceConfigDeviceMap{
+				SDA: &linodego.InstanceConfigDevice{DiskID: 1},
+			},
+			wantKernel: "linode/latest-64bit",
+		},
+		{
+			name:       "Ignition scratch disk",
+			extraSpecs: `{"disks": {"scratch": {"size": 40960, "mount_path": "/var/lib/docker"}}}`,
+			image:      "linode/flatcar",
+			wantDisks: []linodego.InstanceDiskCreateOptions{
+				{Label: "root", Size: 40448, Image: "linode/flatcar"},
+				{Label: "swap", Size: 512, Filesystem: "swap"},
+				{Label: "scratch", Size: 40960, Filesystem: "ext4"},
+			},
+			wantDevices: linodego.InstanceConfigDeviceMap{
+				SDA: &linodego.InstanceConfigDevice{DiskID: 1},
+				SDB: &linodego.InstanceConfigDevice{DiskID: 2},
+				SDC: &linodego.InstanceConfigDevice{DiskID: 3},
+			},
+			wantKernel: "linode/direct-disk",
+			validate: func(t *testing.T, userData []byte) {
+				cfg, rpt, err := ignition.Parse(userData)
+				require.NoError(t, err, rpt.String())
+
+				require.Len(t, cfg.Storage.Filesystems, 1)
+				assert.Equal(t, cfg.Storage.Filesystems[0].Device, "/dev/sdc")
+				assert.Equal(t, *cfg.Storage.Filesystems[0].Format, "ext4")
+				assert.Equal(t, *cfg.Storage.Filesystems[0].WipeFilesystem, false)
+
+				require.Len(t, cfg.Systemd.Units, 2)
+				assert.Equal(t, cfg.Systemd.Units[0].Name, "var-lib-docker.mount")
+				assert.Contains(t, *cfg.Systemd.Units[0].Contents, "What=/dev/sdc\n")
+				assert.Contains(t, *cfg.Systemd.Units[1].Contents, "RequiresMountsFor=/var/lib/docker\n")
+			},
+		},
+		{
+			name:         "Disks larger than the plan disk",
+			extraSpecs:   `{"disks": {"root_size": 81920}}`,
+			image:        "linode/ubuntu24.04",
+			wantErr:      "getting disk layout: root (81920 MB), swap (512 MB) and scratch (0 MB) disks do not fit on the 81920 MB plan disk",
+			wantAPICalls: []string{MockGetType},
+		},
+		{
+			name:          "Disk creation failure rolls back the instance",
+			extraSpecs:    `{"disks": {"root_size": 20480}}`,
+			image:         "linode/ubuntu24.04",
+			createDiskErr: &linodego.Error{Code: 400, Message: "Image too large"},
+			wantErr:       "creating disks: creating root disk from Linode API: [400] Image too large",
+			wantAPICalls:  []string{MockGetType, MockCreateInstance, MockCreateInstanceDisk, MockListEvents, MockDeleteInstance},
+		},
+		{
+			name:         "Disks waited for as long as the runner",
+			extraSpecs:   `{"disks": {"root_size": 20480}, "wait": {"timeout": "20ms", "poll_interval": "1ms"}}`,
+			image:        "linode/ubuntu24.04",
+			diskNotReady: true,
+			wantErr:      "creating disks: waiting for disk 1 to be ready: time limit of 20ms exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskID := 0
+			configID := 0
+			m := &mockLinode{
+				calls: []call{},
+				getType: func(ctx context.Context, ID string) (*linodego.LinodeType, error) {
+					return &linodego.LinodeType{ID: ID, Disk: 81920}, nil
+				},
+				createInstance: func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceOffline,
+					}, nil
+				},
+				createInstanceDisk: func(ctx context.Context, ID int, opts linodego.InstanceDiskCreateOptions) (*linodego.InstanceDisk, error) {
+					if tt.createDiskErr != nil {
+						return nil, tt.createDiskErr
+					}
+
+					diskID++
+					return &linodego.InstanceDisk{ID: diskID, Status: linodego.DiskNotReady}, nil
+				},
+				getInstanceDisk: func(ctx context.Context, ID int, diskID int) (*linodego.InstanceDisk, error) {
+					if tt.diskNotReady {
+						return &linodego.InstanceDisk{ID: diskID, Status: linodego.DiskNotReady}, nil
+					}
+
+					return &linodego.InstanceDisk{ID: diskID, Status: linodego.DiskReady}, nil
+				},
+				createInstanceConfig: func(ctx context.Context, ID int, opts linodego.InstanceConfigCreateOptions) (*linodego.InstanceConfig, error) {
+					return &linodego.InstanceConfig{ID: 4242}, nil
+				},
+				bootInstance: func(ctx context.Context, ID int, cID int) error {
+					configID = cID
+					return nil
+				},
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &linodego.Instance{
+						ID:     9876,
+						Status: linodego.InstanceRunning,
+					}, nil
+				},
+			}
+
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			_, err = cli.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-4",
+				Image:  tt.image,
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				ExtraSpecs: json.RawMessage(tt.extraSpecs),
+				PoolID:     "test-pool",
+			})
+
+			names := make([]string, len(m.calls))
+			for i, c := range m.calls {
+				names[i] = c.name
+			}
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				// The disks are polled until the timeout.
+				if !tt.diskNotReady {
+					assert.Equal(t, names, tt.wantAPICalls)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			// The instance is created without disks, the image is
+			// deployed on the root disk.
+			opts, ok := m.calls[1].args.(linodego.InstanceCreateOptions)
+			require.True(t, ok)
+			assert.Empty(t, opts.Image)
+			assert.Empty(t, opts.RootPass)
+			assert.False(t, *opts.Booted)
+			assert.Contains(t, opts.Tags, "garm-image="+tt.image)
+
+			var disks []linodego.InstanceDiskCreateOptions
+			for _, c := range m.calls {
+				if c.name == MockCreateInstanceDisk {
+					disk, ok := c.args.(linodego.InstanceDiskCreateOptions)
+					require.True(t, ok)
+					disks = append(disks, disk)
+				}
+			}
+			require.Len(t, disks, len(tt.wantDisks))
+			assert.NotEmpty(t, disks[0].RootPass)
+			disks[0].RootPass = ""
+			assert.Equal(t, disks, tt.wantDisks)
+
+			i := slices.Index(names, MockCreateInstanceConfig)
+			require.NotEqual(t, i, -1)
+			cfg, ok := m.calls[i].args.(linodego.InstanceConfigCreateOptions)
+			require.True(t, ok)
+			assert.Equal(t, cfg.Devices, tt.wantDevices)
+			assert.Equal(t, cfg.Kernel, tt.wantKernel)
+			assert.Equal(t, *cfg.RootDevice, "/dev/sda")
+
+			// The disks are ready before the configuration profile is
+			// created and booted.
+			assert.Equal(t, slices.Index(names, MockGetInstanceDisk), i-len(tt.wantDisks))
+			assert.Equal(t, names[i+1], MockBootInstance)
+			assert.Equal(t, configID, 4242)
+
+			if tt.validate != nil {
+				userData, err := base64.StdEncoding.DecodeString(opts.Metadata.UserData)
+				require.NoError(t, err)
+				tt.validate(t, userData)
+			}
+		})
+	}
+}
+
+func TestDeleteInstance(t *testing.T) {
+	getInstance := func(ctx context.Context, ID int) (*linodego.Instance, error) {
+		return &linodego.Instance{
+			ID:   ID,
+			Tags: []string{"garm-controller-id=1234"},
+		}, nil
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		m := &mockLinode{
+			calls:       []call{},
+			getInstance: getInstance,
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "9876")
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 3)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
+		assert.Equal(t, m.calls[1].name, MockListInstanceVolumes)
+		c := m.calls[2]
+		assert.Equal(t, c.name, MockDeleteInstance)
+
+		opts, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, opts, 9876)
+	})
+
+	t.Run("Success from ID not being an ID", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:    9876,
+					Tags:  []string{"garm-controller-id=1234"},
+					Label: "foo",
+				}, nil
+			},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:    9876,
+						Tags:  []string{"garm-controller-id=1234"},
+						Label: "foo",
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "foo")
+		require.Nil(t, err)
+
+		require.Len(t, m.calls, 3)
+
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"label":"foo"},{"tags":"garm-controller-id=1234"}]}`)
+
+		c = m.calls[2]
+		assert.Equal(t, c.name, MockDeleteInstance)
+
+		ID, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, ID, 9876)
+	})
+
+	t.Run("Fail from API", func(t *testing.T) {
+		m := &mockLinode{
+			calls:       []call{},
+			getInstance: getInstance,
+			deleteInstance: func(ctx context.Context, ID int) error {
+				return fmt.Errorf("random error from the API")
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "9876")
+		assert.ErrorContains(t, err, "deleting instance from Linode API: random error from the API")
+
+		require.Len(t, m.calls, 3)
+		c := m.calls[2]
+		assert.Equal(t, c.name, MockDeleteInstance)
+
+		opts, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, opts, 9876)
+	})
+
+	t.Run("Success deleting the volumes", func(t *testing.T) {
+		detached := map[int]bool{}
+		m := &mockLinode{
+			calls:       []call{},
+			getInstance: getInstance,
+			listInstanceVolumes: func(ctx context.Context, ID int, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+				return []linodego.Volume{
+					{ID: 5555, LinodeID: &ID, Tags: []string{"garm-controller-id=1234"}},
+					// Attached by hand, not by this controller.
+					{ID: 6666, LinodeID: &ID},
+				}, nil
+			},
+			getVolume: func(ctx context.Context, ID int) (*linodego.Volume, error) {
+				// The volume is detached shortly after the
+				// instance is deleted.
+				if !detached[ID] {
+					detached[ID] = true
+					return &linodego.Volume{ID: ID, LinodeID: ptr(9876)}, nil
+				}
+
+				return &linodego.Volume{ID: ID}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					PollInterval: config.Duration(time.Millisecond),
+				},
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
 		err = cli.DeleteInstance(t.Context(), "9876")
-		assert.ErrorContains(t, err, "deleting instance from Linode API: random error from the API")
+		require.NoError(t, err)
+
+		names := make([]string, len(m.calls))
+		for i, c := range m.calls {
+			names[i] = c.name
+		}
+		assert.Equal(t, names, []string{
+			MockGetInstance,
+			MockListInstanceVolumes,
+			MockDeleteInstance,
+			MockGetVolume,
+			MockGetVolume,
+			MockDeleteVolume,
+		})
+		assert.Equal(t, m.calls[5].args, 5555)
+	})
+
+	t.Run("Success releasing the cache volume", func(t *testing.T) {
+		m := &mockLinode{
+			calls:       []call{},
+			getInstance: getInstance,
+			listInstanceVolumes: func(ctx context.Context, ID int, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+				return []linodego.Volume{
+					{ID: 5555, LinodeID: &ID, Tags: []string{client.TagCache, "garm-controller-id=1234", "garm-claimed-by=9876"}},
+				}, nil
+			},
+			getVolume: func(ctx context.Context, ID int) (*linodego.Volume, error) {
+				return &linodego.Volume{ID: ID, Tags: []string{client.TagCache, "garm-controller-id=1234", "garm-claimed-by=9876"}}, nil
+			},
+		}
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "9876")
+		require.NoError(t, err)
+
+		names := make([]string, len(m.calls))
+		for i, c := range m.calls {
+			names[i] = c.name
+		}
+		assert.Equal(t, names, []string{
+			MockGetInstance,
+			MockListInstanceVolumes,
+			MockDeleteInstance,
+			MockGetVolume,
+			MockUpdateVolume,
+		})
+
+		opts, ok := m.calls[4].args.(linodego.VolumeUpdateOptions)
+		require.True(t, ok)
+		require.NotNil(t, opts.Tags)
+		assert.Equal(t, *opts.Tags, []string{client.TagCache, "garm-controller-id=1234"})
+	})
+
+	t.Run("Success releasing the managed placement group", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			label     string
+			members   []linodego.PlacementGroupMember
+			wantCalls []string
+		}{
+			{
+				name:      "last member",
+				label:     "garm-03ac6742-2bee061e64ba9ae6-us-ord",
+				members:   []linodego.PlacementGroupMember{{LinodeID: 9876}},
+				wantCalls: []string{MockGetPlacementGroup, MockDeletePlacementGroup},
+			},
+			{
+				name:      "other members",
+				label:     "garm-03ac6742-2bee061e64ba9ae6-us-ord",
+				members:   []linodego.PlacementGroupMember{{LinodeID: 9876}, {LinodeID: 1111}},
+				wantCalls: []string{MockGetPlacementGroup},
+			},
+			{
+				name:      "not managed",
+				label:     "builds",
+				wantCalls: []string{},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				m := &mockLinode{
+					calls: []call{},
+					getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+						return &linodego.Instance{
+							ID:             ID,
+							Tags:           []string{"garm-controller-id=1234"},
+							PlacementGroup: &linodego.InstancePlacementGroup{ID: 4242, Label: tt.label},
+						}, nil
+					},
+					getPlacementGroup: func(ctx context.Context, ID int) (*linodego.PlacementGroup, error) {
+						return &linodego.PlacementGroup{ID: ID, Label: tt.label, Members: tt.members}, nil
+					},
+				}
+
+				cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+				require.NoError(t, err)
+
+				err = cli.DeleteInstance(t.Context(), "9876")
+				require.NoError(t, err)
+
+				names := []string{}
+				for _, c := range m.calls[3:] {
+					names = append(names, c.name)
+				}
+				assert.Equal(t, names, tt.wantCalls)
+
+				if len(tt.wantCalls) == 2 {
+					assert.Equal(t, m.calls[4].args, 4242)
+				}
+			})
+		}
+	})
+
+	t.Run("Success when already deleted", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "9876")
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 1)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
+	})
+
+	t.Run("Success when deleted concurrently", func(t *testing.T) {
+		m := &mockLinode{
+			calls:       []call{},
+			getInstance: getInstance,
+			deleteInstance: func(ctx context.Context, ID int) error {
+				return &linodego.Error{Code: 404, Message: "Not found"}
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "9876")
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 3)
+		assert.Equal(t, m.calls[2].name, MockDeleteInstance)
+	})
+
+	t.Run("Success from ID not being an ID and no match on the name", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:    9876,
+					Tags:  []string{"garm-controller-id=1234"},
+					Label: "foo",
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.DeleteInstance(t.Context(), "foo")
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"label":"foo"},{"tags":"garm-controller-id=1234"}]}`)
+	})
+}
+
+func TestGetInstance(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:   1234,
+					Tags: []string{"garm-controller-id=1234"},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		i, err := cli.GetInstance(t.Context(), "9876")
+		require.NoError(t, err)
+		assert.Equal(t, i.ID, 1234)
+
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockGetInstance)
+
+		opts, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, opts, 9876)
+	})
+
+	t.Run("Success from ID not being an ID", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:    9876,
+					Tags:  []string{"garm-controller-id=1234"},
+					Label: "foo",
+				}, nil
+			},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:    9876,
+						Tags:  []string{"garm-controller-id=1234"},
+						Label: "foo",
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		i, err := cli.GetInstance(t.Context(), "foo")
+		require.Nil(t, err)
+		assert.Equal(t, i.ID, 9876)
+
+		// The listed instance is returned as is.
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"label":"foo"},{"tags":"garm-controller-id=1234"}]}`)
+	})
+
+	t.Run("Fail from API", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return nil, fmt.Errorf("random error from the API")
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		i, err := cli.GetInstance(t.Context(), "9876")
+		require.Nil(t, i)
+		assert.ErrorContains(t, err, "getting instance from Linode API: random error from the API")
+
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockGetInstance)
+
+		opts, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, opts, 9876)
+	})
+
+	t.Run("Fail when not found", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		_, err = cli.GetInstance(t.Context(), "9876")
+		assert.EqualError(t, err, "instance 9876 not found")
+		assert.ErrorIs(t, err, gErrors.ErrNotFound)
+	})
+
+	t.Run("Fail from ID not being an ID and no match on the name", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:    9876,
+					Tags:  []string{"garm-controller-id=1234"},
+					Label: "foo",
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		_, err = cli.GetInstance(t.Context(), "foo")
+		assert.ErrorContains(t, err, "getting instance ID by its name: no instances matching this name: foo")
+		assert.ErrorIs(t, err, gErrors.ErrNotFound)
+
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"label":"foo"},{"tags":"garm-controller-id=1234"}]}`)
+	})
+
+	t.Run("Fail from ID not being an ID and several matches on the name", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:    9876,
+						Tags:  []string{"garm-controller-id=1234"},
+						Label: "foo",
+					},
+					{
+						ID:    5432,
+						Tags:  []string{"garm-controller-id=1234"},
+						Label: "foo",
+					},
+					{
+						ID:    1098,
+						Tags:  []string{"garm-controller-id=1234"},
+						Label: "foobar",
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		_, err = cli.GetInstance(t.Context(), "foo")
+		assert.ErrorContains(t, err, "getting instance ID by its name: 2 instances matching this name: foo")
+
+		require.Len(t, m.calls, 1)
+		assert.Equal(t, m.calls[0].name, MockListInstances)
+	})
+}
+
+func TestGetInstanceOS(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance *linodego.Instance
+		image    *linodego.Image
+		imageErr error
+		want     client.InstanceOS
+		wantErr  string
+	}{
+		{
+			name:     "public image",
+			instance: &linodego.Instance{Image: "linode/ubuntu24.04"},
+			image:    &linodego.Image{ID: "linode/ubuntu24.04", Label: "Ubuntu 24.04 LTS", Vendor: "Ubuntu"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "ubuntu", Version: "24.04", Arch: params.Amd64},
+		},
+		{
+			name:     "public image with a dashed name",
+			instance: &linodego.Instance{Image: "linode/centos-stream9"},
+			image:    &linodego.Image{ID: "linode/centos-stream9", Label: "CentOS Stream 9"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "centos-stream", Version: "9", Arch: params.Amd64},
+		},
+		{
+			name:     "public Flatcar image",
+			instance: &linodego.Instance{Image: "linode/flatcar"},
+			image:    &linodego.Image{ID: "linode/flatcar", Label: "Flatcar Container Linux"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "flatcar", Version: "stable", Arch: params.Amd64},
+		},
+		{
+			name:     "private image",
+			instance: &linodego.Instance{Image: "private/1234"},
+			image:    &linodego.Image{ID: "private/1234", Label: "debian-12-runner"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "debian", Version: "12", Arch: params.Amd64},
+		},
+		{
+			name:     "private Flatcar image",
+			instance: &linodego.Instance{Image: "private/1234"},
+			image:    &linodego.Image{ID: "private/1234", Label: "flatcar-beta-4152.1.0"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "flatcar", Version: "beta", Arch: params.Amd64},
+		},
+		{
+			name:     "deleted image",
+			instance: &linodego.Instance{Image: "linode/debian11"},
+			imageErr: &linodego.Error{Code: 404, Message: "Not found"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "debian", Version: "11", Arch: params.Amd64},
+		},
+		{
+			name:     "image tag of an instance with custom disks",
+			instance: &linodego.Instance{Tags: []string{"garm-image=linode/ubuntu24.04"}},
+			image:    &linodego.Image{ID: "linode/ubuntu24.04", Label: "Ubuntu 24.04 LTS", Vendor: "Ubuntu"},
+			want:     client.InstanceOS{Type: params.Linux, Name: "ubuntu", Version: "24.04", Arch: params.Amd64},
+		},
+		{
+			name:     "no image",
+			instance: &linodego.Instance{},
+			want:     client.InstanceOS{Type: params.Linux, Arch: params.Amd64},
+		},
+		{
+			name:     "fail from API",
+			instance: &linodego.Instance{Image: "linode/debian12"},
+			imageErr: &linodego.Error{Code: 500, Message: "Internal server error"},
+			want:     client.InstanceOS{Type: params.Linux, Arch: params.Amd64},
+			wantErr:  "getting image from Linode API: [500] Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+					return tt.image, tt.imageErr
+				},
+			}
+
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			os, err := cli.GetInstanceOS(t.Context(), tt.instance)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, os, tt.want)
+		})
+	}
+}
+
+func TestGetInstanceFaults(t *testing.T) {
+	entity := func(id int) *linodego.EventEntity {
+		return &linodego.EventEntity{ID: float64(id), Type: linodego.EntityLinode}
+	}
+	created := ptr(time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name      string
+		events    []linodego.Event
+		eventsErr error
+		want      map[int]string
+		wantErr   string
+	}{
+		{
+			name: "failed events",
+			events: []linodego.Event{
+				{Action: linodego.ActionLinodeBoot, Status: linodego.EventFailed, Entity: entity(9876), Created: created, Message: "Kernel panic"},
+				{Action: linodego.ActionDiskCreate, Status: linodego.EventFailed, Entity: entity(9876), Created: created},
+				{Action: linodego.ActionLinodeCreate, Status: linodego.EventFinished, Entity: entity(9876), Created: created},
+				{Action: linodego.ActionLinodeBoot, Status: linodego.EventFailed, Entity: entity(5432), Created: created},
+			},
+			want: map[int]string{
+				9876: "2026-10-17T10:00:00Z: linode_boot failed: Kernel panic\n2026-10-17T10:00:00Z: disk_create failed",
+				5432: "2026-10-17T10:00:00Z: linode_boot failed",
+			},
+		},
+		{
+			name: "failed events followed by a success",
+			events: []linodego.Event{
+				{Action: linodego.ActionLinodeBoot, Status: linodego.EventFinished, Entity: entity(9876), Created: created},
+				{Action: linodego.ActionLinodeBoot, Status: linodego.EventFailed, Entity: entity(9876), Created: created},
+			},
+			want: map[int]string{},
+		},
+		{
+			name:      "token without access to the events",
+			eventsErr: &linodego.Error{Code: 403, Message: "Unauthorized"},
+		},
+		{
+			name:      "fail from API",
+			eventsErr: &linodego.Error{Code: 500, Message: "Internal server error"},
+			wantErr:   "listing events from Linode API: [500] Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls: []call{},
+				listEvents: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+					return tt.events, tt.eventsErr
+				},
+			}
+
+			cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+			require.NoError(t, err)
+
+			faults, err := cli.GetInstanceFaults(t.Context(), 9876, 5432)
+
+			require.Len(t, m.calls, 1)
+			opts, ok := m.calls[0].args.(*linodego.ListOptions)
+			require.True(t, ok)
+			assert.JSONEq(t, opts.Filter, `{
+				"+and": [
+					{"entity.type": "linode"},
+					{"+or": [{"entity.id": 9876}, {"entity.id": 5432}]}
+				],
+				"+order_by": "created",
+				"+order": "desc"
+			}`)
+
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, faults, tt.want)
+		})
+	}
+}
+
+func TestListInstances(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:   1234,
+						Tags: []string{"garm-pool-id=1234", "garm-controller-id=1234"},
+					},
+					{
+						ID:   5678,
+						Tags: []string{"garm-pool-id=1234", "garm-controller-id=1234"},
+					},
+					{
+						// The API matches tags loosely.
+						ID:   9012,
+						Tags: []string{"garm-pool-id=12345", "garm-controller-id=1234"},
+					},
+					{
+						ID:   3456,
+						Tags: []string{"garm-pool-id=1234", "garm-controller-id=5678"},
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		i, err := cli.ListInstances(t.Context(), "1234")
+		require.NoError(t, err)
+		require.Equal(t, len(i), 2)
+		assert.Equal(t, i[0].ID, 1234)
+		assert.Equal(t, i[1].ID, 5678)
+
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"tags":"garm-pool-id=1234"},{"tags":"garm-controller-id=1234"}]}`)
+	})
+
+	t.Run("Fail from API", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return nil, fmt.Errorf("random error from the API")
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		i, err := cli.ListInstances(t.Context(), "1234")
+		require.Nil(t, i)
+		assert.ErrorContains(t, err, "getting instances list from Linode API: random error from the API")
+
+		require.Len(t, m.calls, 1)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"tags":"garm-pool-id=1234"},{"tags":"garm-controller-id=1234"}]}`)
+	})
+}
+
+func TestRemoveAllInstances(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:   1111,
+						Tags: []string{"garm-controller-id=1234"},
+					},
+					{
+						ID:   2222,
+						Tags: []string{"garm-controller-id=1234"},
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 6)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"tags":"garm-controller-id=1234"}`)
+
+		// Instances are deleted concurrently, in any order.
+		ids := []any{}
+		for _, c := range m.calls[1:3] {
+			assert.Equal(t, c.name, MockDeleteInstance)
+			ids = append(ids, c.args)
+		}
+		assert.ElementsMatch(t, ids, []any{1111, 2222})
+
+		c = m.calls[3]
+		assert.Equal(t, c.name, MockListVolumes)
+
+		opts, ok = c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"tags":"garm-controller-id=1234"}`)
+
+		assert.Equal(t, m.calls[4].name, MockListPlacementGroups)
+
+		c = m.calls[5]
+		assert.Equal(t, c.name, MockListFirewalls)
+
+		opts, ok = c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"tags":"garm-controller-id=1234"}`)
+	})
+
+	t.Run("Success with a bounded concurrency", func(t *testing.T) {
+		var (
+			mu               sync.Mutex
+			running, maxSeen int
+			instances        []linodego.Instance
+		)
+		for i := range 20 {
+			instances = append(instances, linodego.Instance{ID: 1000 + i, Tags: []string{"garm-controller-id=1234"}})
+		}
+
+		m := &mockLinode{
+			calls: []call{},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return instances, nil
+			},
+			deleteInstance: func(ctx context.Context, ID int) error {
+				mu.Lock()
+				running++
+				maxSeen = max(maxSeen, running)
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+
+				return nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token:             "foo",
+				DeleteConcurrency: 4,
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		require.NoError(t, err)
+
+		assert.Len(t, m.calls, 24)
+		assert.LessOrEqual(t, maxSeen, 4)
+		assert.Greater(t, maxSeen, 1)
+	})
+
+	t.Run("Success removing the volumes", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listVolumes: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+				return []linodego.Volume{
+					{ID: 5555, Tags: []string{"garm-controller-id=1234"}},
+					// Loosely matched by the API.
+					{ID: 6666, Tags: []string{"garm-controller-id=12345"}},
+				}, nil
+			},
+			getVolume: func(ctx context.Context, ID int) (*linodego.Volume, error) {
+				return &linodego.Volume{ID: ID}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 6)
+		assert.Equal(t, m.calls[1].name, MockListVolumes)
+		assert.Equal(t, m.calls[2].name, MockGetVolume)
+
+		c := m.calls[3]
+		assert.Equal(t, c.name, MockDeleteVolume)
+		assert.Equal(t, c.args, 5555)
+	})
+
+	t.Run("Success removing the managed placement groups", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listPlacementGroups: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.PlacementGroup, error) {
+				return []linodego.PlacementGroup{
+					{ID: 4242, Label: "garm-03ac6742-2bee061e64ba9ae6-us-ord"},
+					// Managed by another controller.
+					{ID: 5555, Label: "garm-fe2592b4-2bee061e64ba9ae6-us-ord"},
+					{ID: 6666, Label: "builds"},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 5)
+		assert.Equal(t, m.calls[2].name, MockListPlacementGroups)
+
+		c := m.calls[3]
+		assert.Equal(t, c.name, MockDeletePlacementGroup)
+		assert.Equal(t, c.args, 4242)
+	})
+
+	t.Run("Success removing the managed firewall", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listFirewalls: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Firewall, error) {
+				return []linodego.Firewall{
+					{
+						ID:   3333,
+						Tags: []string{"garm-controller-id=1234"},
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token:           "foo",
+				ManagedFirewall: true,
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 5)
+		assert.Equal(t, m.calls[0].name, MockListInstances)
+		assert.Equal(t, m.calls[1].name, MockListVolumes)
+		assert.Equal(t, m.calls[2].name, MockListPlacementGroups)
+		assert.Equal(t, m.calls[3].name, MockListFirewalls)
+
+		c := m.calls[4]
+		assert.Equal(t, c.name, MockDeleteFirewall)
+
+		id, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, id, 3333)
+	})
+
+	t.Run("Fail to list instances", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return nil, fmt.Errorf("random error from the API")
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		assert.ErrorContains(t, err, "getting instances list from Linode API: random error from the API")
 
 		require.Len(t, m.calls, 1)
 		c := m.calls[0]
-		assert.Equal(t, c.name, MockDeleteInstance)
+		assert.Equal(t, c.name, MockListInstances)
+	})
+
+	t.Run("Fail to delete some instances", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			deleteInstance: func(ctx context.Context, ID int) error {
+				if ID == 2222 {
+					return nil
+				}
+
+				return fmt.Errorf("random error from the API")
+			},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:   1111,
+						Tags: []string{"garm-controller-id=1234"},
+					},
+					{
+						ID:   2222,
+						Tags: []string{"garm-controller-id=1234"},
+					},
+					{
+						ID:   3333,
+						Tags: []string{"garm-controller-id=1234"},
+					},
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token:           "foo",
+				ManagedFirewall: true,
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.RemoveAllInstances(t.Context())
+		assert.EqualError(t, err, "deleting instance 1111: deleting instance from Linode API: random error from the API\n"+
+			"deleting instance 3333: deleting instance from Linode API: random error from the API")
+
+		// Every instance is tried, and the firewall is kept for the
+		// remaining instances.
+		require.Len(t, m.calls, 4)
+		assert.Equal(t, m.calls[0].name, MockListInstances)
+
+		ids := []any{}
+		for _, c := range m.calls[1:] {
+			assert.Equal(t, c.name, MockDeleteInstance)
+			ids = append(ids, c.args)
+		}
+		assert.ElementsMatch(t, ids, []any{1111, 2222, 3333})
+	})
+}
+
+func TestSharedAccount(t *testing.T) {
+	// The account is shared by the controllers 1234 and 5678, and holds an
+	// instance not managed by GARM.
+	account := []linodego.Instance{
+		{ID: 1111, Label: "runner-a", Status: linodego.InstanceRunning, Tags: []string{"garm-pool-id=a", "garm-controller-id=1234"}},
+		{ID: 2222, Label: "runner-b", Status: linodego.InstanceRunning, Tags: []string{"garm-pool-id=b", "garm-controller-id=5678"}},
+		{ID: 3333, Label: "web", Status: linodego.InstanceRunning},
+	}
+
+	operations := map[string]struct {
+		run      func(ctx context.Context, cli *client.Linode, ID string) error
+		mutation string
+		// idempotent operations succeed on the instances not found.
+		idempotent bool
+	}{
+		"get": {
+			run: func(ctx context.Context, cli *client.Linode, ID string) error {
+				_, err := cli.GetInstance(ctx, ID)
+				return err
+			},
+		},
+		"delete": {
+			run: func(ctx context.Context, cli *client.Linode, ID string) error {
+				return cli.DeleteInstance(ctx, ID)
+			},
+			mutation:   MockDeleteInstance,
+			idempotent: true,
+		},
+		"stop": {
+			run: func(ctx context.Context, cli *client.Linode, ID string) error {
+				return cli.StopInstance(ctx, ID, true)
+			},
+			mutation: MockShutdownInstance,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ID      string
+		wantErr string
+	}{
+		{
+			name: "own instance by ID",
+			ID:   "1111",
+		},
+		{
+			name: "own instance by label",
+			ID:   "runner-a",
+		},
+		{
+			name:    "other controller instance by ID",
+			ID:      "2222",
+			wantErr: "instance 2222 not found in this controller",
+		},
+		{
+			name:    "other controller instance by label",
+			ID:      "runner-b",
+			wantErr: "getting instance ID by its name: no instances matching this name: runner-b",
+		},
+		{
+			name:    "unmanaged instance by ID",
+			ID:      "3333",
+			wantErr: "instance 3333 not found in this controller",
+		},
+		{
+			name:    "unmanaged instance by label",
+			ID:      "web",
+			wantErr: "getting instance ID by its name: no instances matching this name: web",
+		},
+	}
+
+	for _, tt := range tests {
+		for opName, op := range operations {
+			t.Run(tt.name+" ("+opName+")", func(t *testing.T) {
+				status := linodego.InstanceRunning
+				m := &mockLinode{
+					calls: []call{},
+					getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+						for _, i := range account {
+							if i.ID == ID {
+								i.Status = status
+								return &i, nil
+							}
+						}
+
+						return nil, &linodego.Error{Code: 404, Message: "Not found"}
+					},
+					// The tags are not filtered, to make sure they
+					// are checked by the client.
+					listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+						var instances []linodego.Instance
+						for _, i := range account {
+							if strings.Contains(opts.Filter, fmt.Sprintf(`"label":%q`, i.Label)) {
+								instances = append(instances, i)
+							}
+						}
+
+						return instances, nil
+					},
+					shutdownInstance: func(ctx context.Context, ID int) error {
+						status = linodego.InstanceOffline
+						return nil
+					},
+				}
+
+				cli, err := client.New(&config.Config{Token: "foo"}, m, "1234")
+				require.NoError(t, err)
+
+				err = op.run(t.Context(), cli, tt.ID)
+
+				mutated := slices.ContainsFunc(m.calls, func(c call) bool {
+					return c.name == op.mutation
+				})
+
+				if tt.wantErr != "" {
+					if op.idempotent {
+						require.NoError(t, err)
+					} else {
+						require.EqualError(t, err, tt.wantErr)
+						require.ErrorIs(t, err, gErrors.ErrNotFound)
+					}
+					assert.False(t, mutated)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, mutated, op.mutation != "")
+			})
+		}
+	}
+}
+
+func TestStopInstance(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		status := linodego.InstanceRunning
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}, nil
+			},
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				status = linodego.InstanceOffline
+				return nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.StopInstance(t.Context(), "9876", false)
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 3)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
+
+		c := m.calls[1]
+		assert.Equal(t, c.name, MockShutdownInstance)
+
+		ID, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, ID, 9876)
+
+		assert.Equal(t, m.calls[2].name, MockGetInstance)
+	})
+
+	t.Run("Success from ID not being an ID", func(t *testing.T) {
+		status := linodego.InstanceRunning
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Label:  "foo",
+					Status: status,
+				}, nil
+			},
+			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+				return []linodego.Instance{
+					{
+						ID:     9876,
+						Tags:   []string{"garm-controller-id=1234"},
+						Label:  "foo",
+						Status: linodego.InstanceRunning,
+					},
+				}, nil
+			},
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				status = linodego.InstanceOffline
+				return nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.StopInstance(t.Context(), "foo", true)
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 3)
+		c := m.calls[0]
+		assert.Equal(t, c.name, MockListInstances)
+
+		opts, ok := c.args.(*linodego.ListOptions)
+		require.True(t, ok)
+		assert.Equal(t, opts.Filter, `{"+and":[{"label":"foo"},{"tags":"garm-controller-id=1234"}]}`)
+
+		c = m.calls[1]
+		assert.Equal(t, c.name, MockShutdownInstance)
+
+		ID, ok := c.args.(int)
+		require.True(t, ok)
+		assert.Equal(t, ID, 9876)
+	})
+
+	t.Run("Success when already offline", func(t *testing.T) {
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: linodego.InstanceOffline,
+				}, nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.StopInstance(t.Context(), "9876", false)
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 1)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
+	})
+
+	t.Run("Success forcing a booting instance", func(t *testing.T) {
+		status := linodego.InstanceBooting
+		m := &mockLinode{
+			calls: []call{},
+			// The instance is done booting once looked up.
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				instance := &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}
+				if status == linodego.InstanceBooting {
+					status = linodego.InstanceRunning
+				}
+				return instance, nil
+			},
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				if status != linodego.InstanceRunning {
+					return errLinodeBusy
+				}
+				status = linodego.InstanceOffline
+				return nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					PollInterval: config.Duration(time.Millisecond),
+				},
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.StopInstance(t.Context(), "9876", true)
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 4)
+		assert.Equal(t, m.calls[1].name, MockGetInstance)
+		assert.Equal(t, m.calls[2].name, MockShutdownInstance)
+		assert.Equal(t, m.calls[3].name, MockGetInstance)
+	})
+
+	t.Run("Success forcing an instance shutting down", func(t *testing.T) {
+		status := linodego.InstanceShuttingDown
+		m := &mockLinode{
+			calls: []call{},
+			// The shutdown in progress completes once looked up.
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				instance := &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}
+				status = linodego.InstanceOffline
+				return instance, nil
+			},
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				return errLinodeBusy
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					PollInterval: config.Duration(time.Millisecond),
+				},
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
+
+		err = cli.StopInstance(t.Context(), "9876", true)
+		require.NoError(t, err)
+
+		require.Len(t, m.calls, 2)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
+		assert.Equal(t, m.calls[1].name, MockGetInstance)
+	})
+
+	t.Run("Fail gracefully stopping within the wait timeout", func(t *testing.T) {
+		status := linodego.InstanceRunning
+		shutdowns := 0
+		m := &mockLinode{
+			calls: []call{},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}, nil
+			},
+			// The guest does not power off, another shutdown would be
+			// refused while the first one is in progress.
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				shutdowns++
+				if status == linodego.InstanceShuttingDown {
+					return errLinodeBusy
+				}
+				status = linodego.InstanceShuttingDown
+				return nil
+			},
+		}
+
+		cli, err := client.New(
+			&config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					Timeout:      config.Duration(20 * time.Millisecond),
+					PollInterval: config.Duration(time.Millisecond),
+				},
+			},
+			m,
+			"1234",
+		)
+		require.NoError(t, err)
 
-		opts, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, opts, 9876)
+		err = cli.StopInstance(t.Context(), "9876", false)
+		assert.EqualError(t, err, "getting instance offline: time limit of 20ms exceeded (last status: shutting_down)")
+		assert.Equal(t, shutdowns, 1)
 	})
 
-	t.Run("Fail from ID not being an ID and no match on the name", func(t *testing.T) {
+	t.Run("Fail forcing the stop within the wait timeout", func(t *testing.T) {
 		m := &mockLinode{
 			calls: []call{},
 			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
 				return &linodego.Instance{
-					ID:    9876,
-					Label: "foo",
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: linodego.InstanceBooting,
 				}, nil
 			},
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				return errLinodeBusy
+			},
 		}
 
 		cli, err := client.New(
 			&config.Config{
 				Token: "foo",
+				Wait: &config.Wait{
+					Timeout:      config.Duration(20 * time.Millisecond),
+					PollInterval: config.Duration(time.Millisecond),
+				},
 			},
 			m,
 			"1234",
 		)
 		require.NoError(t, err)
 
-		err = cli.DeleteInstance(t.Context(), "foo")
-		assert.ErrorContains(t, err, "getting instance ID by its name: no instances matching this name: foo")
-
-		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
-
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, `{"label":"foo"}`)
+		err = cli.StopInstance(t.Context(), "9876", true)
+		assert.EqualError(t, err, "getting instance settled: time limit of 20ms exceeded (last status: booting)")
+		for _, c := range m.calls {
+			assert.NotEqual(t, c.name, MockShutdownInstance)
+		}
 	})
-}
 
-func TestGetInstance(t *testing.T) {
-	t.Run("Success", func(t *testing.T) {
+	t.Run("Fail gracefully stopping a busy instance", func(t *testing.T) {
 		m := &mockLinode{
 			calls: []call{},
 			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
 				return &linodego.Instance{
-					ID: 1234,
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: linodego.InstanceBooting,
 				}, nil
 			},
 		}
@@ -310,34 +4640,25 @@ func TestGetInstance(t *testing.T) {
 		)
 		require.NoError(t, err)
 
-		i, err := cli.GetInstance(t.Context(), "9876")
-		require.NoError(t, err)
-		assert.Equal(t, i.ID, 1234)
+		err = cli.StopInstance(t.Context(), "9876", false)
+		assert.ErrorContains(t, err, "instance 9876 is booting, refusing to shut it down gracefully")
 
 		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockGetInstance)
-
-		opts, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, opts, 9876)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
 	})
 
-	t.Run("Success from ID not being an ID", func(t *testing.T) {
+	t.Run("Fail from API", func(t *testing.T) {
 		m := &mockLinode{
 			calls: []call{},
 			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
 				return &linodego.Instance{
-					ID:    9876,
-					Label: "foo",
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: linodego.InstanceRunning,
 				}, nil
 			},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return []linodego.Instance{
-					{
-						ID: 9876,
-					},
-				}, nil
+			shutdownInstance: func(ctx context.Context, ID int) error {
+				return fmt.Errorf("random error from the API")
 			},
 		}
 
@@ -350,30 +4671,29 @@ func TestGetInstance(t *testing.T) {
 		)
 		require.NoError(t, err)
 
-		_, err = cli.GetInstance(t.Context(), "foo")
-		require.Nil(t, err)
+		err = cli.StopInstance(t.Context(), "9876", true)
+		assert.ErrorContains(t, err, "shutting down instance from Linode API: random error from the API")
 
 		require.Len(t, m.calls, 2)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
-
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, `{"label":"foo"}`)
-
-		c = m.calls[1]
-		assert.Equal(t, c.name, MockGetInstance)
-
-		ID, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, ID, 9876)
+		assert.Equal(t, m.calls[1].name, MockShutdownInstance)
 	})
+}
 
-	t.Run("Fail from API", func(t *testing.T) {
+func TestStartInstance(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		status := linodego.InstanceOffline
 		m := &mockLinode{
 			calls: []call{},
 			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
-				return nil, fmt.Errorf("random error from the API")
+				return &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}, nil
+			},
+			bootInstance: func(ctx context.Context, ID int, configID int) error {
+				status = linodego.InstanceRunning
+				return nil
 			},
 		}
 
@@ -386,26 +4706,30 @@ func TestGetInstance(t *testing.T) {
 		)
 		require.NoError(t, err)
 
-		i, err := cli.GetInstance(t.Context(), "9876")
-		require.Nil(t, i)
-		assert.ErrorContains(t, err, "getting instance from Linode API: random error from the API")
+		err = cli.StartInstance(t.Context(), "9876")
+		require.NoError(t, err)
 
-		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockGetInstance)
+		require.Len(t, m.calls, 3)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
 
-		opts, ok := c.args.(int)
+		c := m.calls[1]
+		assert.Equal(t, c.name, MockBootInstance)
+
+		ID, ok := c.args.(int)
 		require.True(t, ok)
-		assert.Equal(t, opts, 9876)
+		assert.Equal(t, ID, 9876)
+
+		assert.Equal(t, m.calls[2].name, MockGetInstance)
 	})
 
-	t.Run("Fail from ID not being an ID and no match on the name", func(t *testing.T) {
+	t.Run("Success when already running", func(t *testing.T) {
 		m := &mockLinode{
 			calls: []call{},
 			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
 				return &linodego.Instance{
-					ID:    9876,
-					Label: "foo",
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: linodego.InstanceRunning,
 				}, nil
 			},
 		}
@@ -419,102 +4743,108 @@ func TestGetInstance(t *testing.T) {
 		)
 		require.NoError(t, err)
 
-		_, err = cli.GetInstance(t.Context(), "foo")
-		assert.ErrorContains(t, err, "getting instance ID by its name: no instances matching this name: foo")
+		err = cli.StartInstance(t.Context(), "9876")
+		require.NoError(t, err)
 
 		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
-
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, `{"label":"foo"}`)
+		assert.Equal(t, m.calls[0].name, MockGetInstance)
 	})
-}
 
-func TestListInstances(t *testing.T) {
-	t.Run("Success", func(t *testing.T) {
+	t.Run("Success once the instance is done booting", func(t *testing.T) {
+		status := linodego.InstanceBooting
 		m := &mockLinode{
 			calls: []call{},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return []linodego.Instance{
-					{
-						ID: 1234,
-					},
-					{
-						ID: 5678,
-					},
-				}, nil
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				instance := &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}
+				status = linodego.InstanceRunning
+				return instance, nil
+			},
+			bootInstance: func(ctx context.Context, ID int, configID int) error {
+				return errLinodeBusy
 			},
 		}
 
 		cli, err := client.New(
 			&config.Config{
 				Token: "foo",
+				Wait: &config.Wait{
+					PollInterval: config.Duration(time.Millisecond),
+				},
 			},
 			m,
 			"1234",
 		)
 		require.NoError(t, err)
 
-		i, err := cli.ListInstances(t.Context(), "1234")
+		err = cli.StartInstance(t.Context(), "9876")
 		require.NoError(t, err)
-		assert.Equal(t, len(i), 2)
 
-		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
-
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, fmt.Sprintf(`{"tags":"%s=1234"}`, client.TagPool))
+		require.Len(t, m.calls, 2)
+		assert.Equal(t, m.calls[1].name, MockGetInstance)
 	})
 
-	t.Run("Fail from API", func(t *testing.T) {
+	t.Run("Success once the instance is shut down", func(t *testing.T) {
+		status := linodego.InstanceShuttingDown
 		m := &mockLinode{
 			calls: []call{},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return nil, fmt.Errorf("random error from the API")
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				instance := &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: status,
+				}
+				if status == linodego.InstanceShuttingDown {
+					status = linodego.InstanceOffline
+				}
+				return instance, nil
+			},
+			bootInstance: func(ctx context.Context, ID int, configID int) error {
+				if status != linodego.InstanceOffline {
+					return errLinodeBusy
+				}
+				status = linodego.InstanceRunning
+				return nil
 			},
 		}
 
 		cli, err := client.New(
 			&config.Config{
 				Token: "foo",
+				Wait: &config.Wait{
+					PollInterval: config.Duration(time.Millisecond),
+				},
 			},
 			m,
 			"1234",
 		)
 		require.NoError(t, err)
 
-		i, err := cli.ListInstances(t.Context(), "1234")
-		require.Nil(t, i)
-		assert.ErrorContains(t, err, "getting instances list from Linode API: random error from the API")
-
-		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
+		err = cli.StartInstance(t.Context(), "9876")
+		require.NoError(t, err)
 
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, fmt.Sprintf(`{"tags":"%s=1234"}`, client.TagPool))
+		require.Len(t, m.calls, 4)
+		assert.Equal(t, m.calls[1].name, MockGetInstance)
+		assert.Equal(t, m.calls[2].name, MockBootInstance)
+		assert.Equal(t, m.calls[3].name, MockGetInstance)
 	})
-}
 
-func TestRemoveAllInstances(t *testing.T) {
-	t.Run("Success", func(t *testing.T) {
+	t.Run("Fail from API", func(t *testing.T) {
 		m := &mockLinode{
 			calls: []call{},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return []linodego.Instance{
-					{
-						ID: 1111,
-					},
-					{
-						ID: 2222,
-					},
+			getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+				return &linodego.Instance{
+					ID:     ID,
+					Tags:   []string{"garm-controller-id=1234"},
+					Status: linodego.InstanceOffline,
 				}, nil
 			},
+			bootInstance: func(ctx context.Context, ID int, configID int) error {
+				return fmt.Errorf("random error from the API")
+			},
 		}
 
 		cli, err := client.New(
@@ -526,38 +4856,16 @@ func TestRemoveAllInstances(t *testing.T) {
 		)
 		require.NoError(t, err)
 
-		err = cli.RemoveAllInstances(t.Context())
-		require.NoError(t, err)
-
-		require.Len(t, m.calls, 3)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
-
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, `{"tags":"garm-controller-id=1234"}`)
-
-		c = m.calls[1]
-		assert.Equal(t, c.name, MockDeleteInstance)
-
-		id, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, id, 1111)
-
-		c = m.calls[2]
-		assert.Equal(t, c.name, MockDeleteInstance)
+		err = cli.StartInstance(t.Context(), "9876")
+		assert.ErrorContains(t, err, "booting instance from Linode API: random error from the API")
 
-		id, ok = c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, id, 2222)
+		require.Len(t, m.calls, 2)
+		assert.Equal(t, m.calls[1].name, MockBootInstance)
 	})
 
-	t.Run("Fail to list instances", func(t *testing.T) {
+	t.Run("Fail from ID not being an ID and no match on the name", func(t *testing.T) {
 		m := &mockLinode{
 			calls: []call{},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return nil, fmt.Errorf("random error from the API")
-			},
 		}
 
 		cli, err := client.New(
@@ -569,54 +4877,594 @@ func TestRemoveAllInstances(t *testing.T) {
 		)
 		require.NoError(t, err)
 
-		err = cli.RemoveAllInstances(t.Context())
-		assert.ErrorContains(t, err, "getting instances list from Linode API: random error from the API")
+		err = cli.StartInstance(t.Context(), "foo")
+		assert.ErrorContains(t, err, "getting instance ID by its name: no instances matching this name: foo")
 
 		require.Len(t, m.calls, 1)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
+		assert.Equal(t, m.calls[0].name, MockListInstances)
 	})
+}
 
-	t.Run("Fail to delete one instance", func(t *testing.T) {
-		m := &mockLinode{
-			calls: []call{},
-			deleteInstance: func(ctx context.Context, ID int) error {
-				return fmt.Errorf("random error from the API")
+func TestValidateExtraSpecs(t *testing.T) {
+	tests := []struct {
+		name       string
+		extraSpecs json.RawMessage
+		wantErr    string
+	}{
+		{
+			name:       "empty",
+			extraSpecs: nil,
+		},
+		{
+			name:       "valid",
+			extraSpecs: json.RawMessage(`{"extra_packages": ["curl"], "extra_context": {"foo": "bar"}}`),
+		},
+		{
+			name:       "invalid type",
+			extraSpecs: json.RawMessage(`{"extra_packages": "curl"}`),
+			wantErr:    "extra_packages: Invalid type. Expected: array, given: string",
+		},
+		{
+			name:       "unknown key",
+			extraSpecs: json.RawMessage(`{"foo": "bar"}`),
+			wantErr:    "Additional property foo is not allowed",
+		},
+		{
+			name:       "valid regions",
+			extraSpecs: json.RawMessage(`{"regions": ["us-ord", "us-sea"]}`),
+		},
+		{
+			name:       "empty region",
+			extraSpecs: json.RawMessage(`{"regions": ["us-ord", ""]}`),
+			wantErr:    "regions.1: String length must be greater than or equal to 1",
+		},
+		{
+			name:       "duplicate region",
+			extraSpecs: json.RawMessage(`{"regions": ["us-ord", "us-ord"]}`),
+			wantErr:    "regions: array items[0,1] must be unique",
+		},
+		{
+			name:       "valid wait",
+			extraSpecs: json.RawMessage(`{"wait": {"timeout": "10m", "poll_interval": "1.5s", "until": "running"}}`),
+		},
+		{
+			name:       "invalid wait duration",
+			extraSpecs: json.RawMessage(`{"wait": {"timeout": "10 minutes"}}`),
+			wantErr:    "wait.timeout: Does not match pattern",
+		},
+		{
+			name:       "negative wait poll interval",
+			extraSpecs: json.RawMessage(`{"wait": {"poll_interval": "-5s"}}`),
+			wantErr:    "wait.poll_interval: Does not match pattern",
+		},
+		{
+			name:       "valid volume",
+			extraSpecs: json.RawMessage(`{"volume": {"size": 100, "filesystem": "xfs", "mount_path": "/var/lib/docker"}}`),
+		},
+		{
+			name:       "volume too small",
+			extraSpecs: json.RawMessage(`{"volume": {"size": 5, "mount_path": "/cache"}}`),
+			wantErr:    "volume.size: Must be greater than or equal to 10",
+		},
+		{
+			name:       "volume without mount path",
+			extraSpecs: json.RawMessage(`{"volume": {"size": 10}}`),
+			wantErr:    "mount_path is required",
+		},
+		{
+			name:       "volume with a relative mount path",
+			extraSpecs: json.RawMessage(`{"volume": {"size": 10, "mount_path": "cache"}}`),
+			wantErr:    "volume.mount_path: Does not match pattern",
+		},
+		{
+			name:       "valid cache volume",
+			extraSpecs: json.RawMessage(`{"cache_volume": {"count": 4, "size": 100, "mount_path": "/cache"}}`),
+		},
+		{
+			name:       "cache volume without count",
+			extraSpecs: json.RawMessage(`{"cache_volume": {"size": 100, "mount_path": "/cache"}}`),
+			wantErr:    "count is required",
+		},
+		{
+			name:       "cache volume with an unknown key",
+			extraSpecs: json.RawMessage(`{"cache_volume": {"count": 4, "size": 100, "mount_path": "/cache", "foo": "bar"}}`),
+			wantErr:    "Additional property foo is not allowed",
+		},
+		{
+			name:       "valid placement group",
+			extraSpecs: json.RawMessage(`{"placement_group": {"policy": "flexible", "fallback": "fail"}}`),
+		},
+		{
+			name:       "placement group with an unknown fallback",
+			extraSpecs: json.RawMessage(`{"placement_group": {"fallback": "retry"}}`),
+			wantErr:    "placement_group.fallback: placement_group.fallback must be one of the following",
+		},
+		{
+			name:       "valid StackScript",
+			extraSpecs: json.RawMessage(`{"stackscript_id": 4242, "stackscript_data": {"docker_version": "27.3"}}`),
+		},
+		{
+			name:       "StackScript data not a string",
+			extraSpecs: json.RawMessage(`{"stackscript_id": 4242, "stackscript_data": {"workers": 4}}`),
+			wantErr:    "Invalid type. Expected: string, given: integer",
+		},
+		{
+			name:       "valid disks",
+			extraSpecs: json.RawMessage(`{"disks": {"root_size": 20480, "swap_size": 0, "scratch": {"size": 40960, "mount_path": "/var/lib/docker"}}}`),
+		},
+		{
+			name:       "negative swap size",
+			extraSpecs: json.RawMessage(`{"disks": {"swap_size": -1}}`),
+			wantErr:    "disks.swap_size: Must be greater than or equal to 0",
+		},
+		{
+			name:       "scratch disk without mount path",
+			extraSpecs: json.RawMessage(`{"disks": {"scratch": {"size": 40960}}}`),
+			wantErr:    "mount_path is required",
+		},
+		{
+			name:       "disks kernel not provided by Linode",
+			extraSpecs: json.RawMessage(`{"disks": {"kernel": "grub2"}}`),
+			wantErr:    "disks.kernel: Does not match pattern",
+		},
+		{
+			name:       "invalid JSON",
+			extraSpecs: json.RawMessage(`{`),
+			wantErr:    "validating extra specs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.ValidateExtraSpecs(tt.extraSpecs)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExtraSpecsJSONSchema(t *testing.T) {
+	schema, err := client.ExtraSpecsJSONSchema()
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal([]byte(schema), &doc))
+
+	// description returns the description of the property at path.
+	description := func(path ...string) string {
+		var node any = doc
+		for _, name := range path {
+			props, ok := node.(map[string]any)["properties"].(map[string]any)
+			require.True(t, ok, path)
+			node = props[name]
+			require.NotNil(t, node, path)
+		}
+
+		desc, _ := node.(map[string]any)["description"].(string)
+		return desc
+	}
+
+	// Descriptions with commas are not cut short.
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{
+			path: []string{"volume"},
+			want: "Block Storage volume created for every runner, mounted at boot and deleted with the runner.",
+		},
+		{
+			path: []string{"cache_volume"},
+			want: "Persistent Block Storage volumes of the pool, each runner claiming a free one and releasing it once deleted.",
+		},
+		{
+			path: []string{"cache_volume", "count"},
+			want: "Number of cache volumes of the pool, created as needed.",
+		},
+		{
+			path: []string{"disks"},
+			want: "Disk layout of the runners, replacing the default one of the plan.",
+		},
+		{
+			path: []string{"disks", "swap_size"},
+			want: "Size of the swap disk in MB, 0 meaning no swap (default: 512).",
+		},
+		{
+			path: []string{"disks", "scratch"},
+			want: "Disk formatted with ext4 and mounted at boot, deleted with the runner.",
+		},
+		{
+			path: []string{"disks", "kernel"},
+			want: "Kernel booting the runners, like linode/grub2 or linode/latest-64bit (default: linode/direct-disk for Flatcar images and linode/grub2 otherwise).",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.path, "."), func(t *testing.T) {
+			assert.Equal(t, description(tt.path...), tt.want)
+		})
+	}
+}
+
+func TestValidatePoolInfo(t *testing.T) {
+	image := func(ctx context.Context, ID string) (*linodego.Image, error) {
+		return &linodego.Image{
+			ID:     ID,
+			Status: linodego.ImageStatusAvailable,
+			Size:   2500,
+		}, nil
+	}
+	typ := func(ctx context.Context, ID string) (*linodego.LinodeType, error) {
+		return &linodego.LinodeType{
+			ID:   ID,
+			Disk: 81920,
+		}, nil
+	}
+
+	tests := []struct {
+		name                  string
+		getImage              func(context.Context, string) (*linodego.Image, error)
+		getType               func(context.Context, string) (*linodego.LinodeType, error)
+		getRegionAvailability func(context.Context, string) ([]linodego.RegionAvailability, error)
+		getFirewall           func(context.Context, int) (*linodego.Firewall, error)
+		getPlacementGroup     func(context.Context, int) (*linodego.PlacementGroup, error)
+		getStackscript        func(context.Context, int) (*linodego.Stackscript, error)
+		image                 string
+		firewallID            int
+		regions               []string
+		extraSpecs            json.RawMessage
+		fromExecutor          bool
+		wantErr               string
+		wantAPICalls          []string
+	}{
+		{
+			name:     "valid",
+			getImage: image,
+			getType:  typ,
+			getRegionAvailability: func(ctx context.Context, region string) ([]linodego.RegionAvailability, error) {
+				return []linodego.RegionAvailability{
+					{
+						Region:    region,
+						Plan:      "g6-standard-2",
+						Available: true,
+					},
+				}, nil
 			},
-			listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
-				return []linodego.Instance{
+		},
+		{
+			name: "image does not exist",
+			getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
+			},
+			wantErr: "image linode/ubuntu24.04 does not exist",
+		},
+		{
+			name: "image not available",
+			getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+				return &linodego.Image{
+					ID:     ID,
+					Status: linodego.ImageStatusPendingUpload,
+				}, nil
+			},
+			wantErr: "image linode/ubuntu24.04 is not available (status: pending_upload)",
+		},
+		{
+			name: "image not replicated in the region",
+			getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+				return &linodego.Image{
+					ID:     ID,
+					Status: linodego.ImageStatusAvailable,
+					Regions: []linodego.ImageRegion{
+						{
+							Region: "us-east",
+							Status: linodego.ImageRegionStatusAvailable,
+						},
+					},
+				}, nil
+			},
+			wantErr: "image linode/ubuntu24.04 is not available in us-ord",
+		},
+		{
+			name:     "type does not exist",
+			getImage: image,
+			getType: func(ctx context.Context, ID string) (*linodego.LinodeType, error) {
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
+			},
+			wantErr: "flavor g6-standard-2 does not exist",
+		},
+		{
+			name:     "type not available in the region",
+			getImage: image,
+			getType:  typ,
+			getRegionAvailability: func(ctx context.Context, region string) ([]linodego.RegionAvailability, error) {
+				return []linodego.RegionAvailability{
 					{
-						ID: 1111,
+						Region:    region,
+						Plan:      "g6-standard-2",
+						Available: false,
 					},
 				}, nil
 			},
-		}
+			wantErr: "g6-standard-2 is not available in us-ord",
+		},
+		{
+			name: "image too large",
+			getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+				return &linodego.Image{
+					ID:     ID,
+					Status: linodego.ImageStatusAvailable,
+					Size:   100000,
+				}, nil
+			},
+			getType: typ,
+			wantErr: "image linode/ubuntu24.04 (100000 MB) does not fit on the g6-standard-2 disk (81920 MB)",
+		},
+		{
+			name:       "negative wait poll interval",
+			extraSpecs: json.RawMessage(`{"wait": {"poll_interval": "-5s"}}`),
+			wantErr:    "validating wait: poll_interval needs to be positive",
+		},
+		{
+			name:       "custom disks",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"disks": {"root_size": 20480, "swap_size": 0, "scratch": {"size": 61440, "mount_path": "/var/lib/docker"}}}`),
+		},
+		{
+			name:       "custom disks too large",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"disks": {"root_size": 20480, "scratch": {"size": 61440, "mount_path": "/var/lib/docker"}}}`),
+			wantErr:    "g6-standard-2 disk layout: root (20480 MB), swap (512 MB) and scratch (61440 MB) disks do not fit on the 81920 MB plan disk",
+		},
+		{
+			name:       "image too large for the root disk",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"disks": {"root_size": 2000}}`),
+			wantErr:    "image linode/ubuntu24.04 (2500 MB) does not fit on the root disk (2000 MB)",
+		},
+		{
+			name:       "scratch disk mount path not absolute",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"disks": {"scratch": {"size": 10240, "mount_path": "docker"}}}`),
+			wantErr:    `validating disks: scratch disk mount path "docker" must be a clean absolute path`,
+		},
+		{
+			name:       "disks kernel not provided by Linode",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"disks": {"kernel": "grub2"}}`),
+			wantErr:    `validating disks: kernel "grub2" must be a Linode kernel, like linode/grub2`,
+		},
+		{
+			name:       "firewall from config",
+			getImage:   image,
+			getType:    typ,
+			firewallID: 1111,
+			getFirewall: func(ctx context.Context, ID int) (*linodego.Firewall, error) {
+				return &linodego.Firewall{
+					ID: ID,
+				}, nil
+			},
+		},
+		{
+			name:       "firewall from extra specs does not exist",
+			getImage:   image,
+			getType:    typ,
+			firewallID: 1111,
+			extraSpecs: json.RawMessage(`{"firewall_id": 2222}`),
+			getFirewall: func(ctx context.Context, ID int) (*linodego.Firewall, error) {
+				if ID == 1111 {
+					return &linodego.Firewall{
+						ID: ID,
+					}, nil
+				}
 
-		cli, err := client.New(
-			&config.Config{
-				Token: "foo",
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
 			},
-			m,
-			"1234",
-		)
-		require.NoError(t, err)
+			wantErr: "firewall 2222 does not exist",
+		},
+		{
+			name:       "placement group from extra specs does not exist",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"placement_group": {"id": 4242}}`),
+			getPlacementGroup: func(ctx context.Context, ID int) (*linodego.PlacementGroup, error) {
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
+			},
+			wantErr: "placement group 4242 does not exist",
+		},
+		{
+			name:     "private image of the account",
+			getImage: image,
+			getType:  typ,
+			image:    "private/1234",
+		},
+		{
+			name: "private image shared by another account",
+			getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+				return &linodego.Image{
+					ID:     ID,
+					Status: linodego.ImageStatusAvailable,
+					ImageSharing: linodego.ImageSharing{
+						SharedBy: &linodego.ImageSharingSharedBy{ShareGroupID: 1},
+					},
+				}, nil
+			},
+			image:   "private/1234",
+			wantErr: "image private/1234 does not belong to the account",
+		},
+		{
+			name:       "StackScript missing a required field",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"stackscript_id": 4242}`),
+			getStackscript: func(ctx context.Context, ID int) (*linodego.Stackscript, error) {
+				return &linodego.Stackscript{
+					ID:     ID,
+					Images: []string{"any/all"},
+					UserDefinedFields: &[]linodego.StackscriptUDF{
+						{Name: "docker_version"},
+						{Name: "garm_runner_name"},
+					},
+				}, nil
+			},
+			wantErr: "stackscript_data: docker_version is required by StackScript 4242",
+		},
+		{
+			name:     "type available in one of the regions",
+			getImage: image,
+			getType:  typ,
+			regions:  []string{"us-ord", "us-sea"},
+			getRegionAvailability: func(ctx context.Context, region string) ([]linodego.RegionAvailability, error) {
+				return []linodego.RegionAvailability{
+					{
+						Region:    region,
+						Plan:      "g6-standard-2",
+						Available: region == "us-sea",
+					},
+				}, nil
+			},
+		},
+		{
+			name:       "type not available in any region from extra specs",
+			getImage:   image,
+			getType:    typ,
+			extraSpecs: json.RawMessage(`{"regions": ["fr-par", "nl-ams"]}`),
+			getRegionAvailability: func(ctx context.Context, region string) ([]linodego.RegionAvailability, error) {
+				return []linodego.RegionAvailability{
+					{
+						Region:    region,
+						Plan:      "g6-standard-2",
+						Available: false,
+					},
+				}, nil
+			},
+			wantErr: "g6-standard-2 is not available in fr-par\ng6-standard-2 is not available in nl-ams",
+		},
+		{
+			name:     "region availability from API",
+			getImage: image,
+			getType:  typ,
+			getRegionAvailability: func(ctx context.Context, region string) ([]linodego.RegionAvailability, error) {
+				return nil, fmt.Errorf("random error from the API")
+			},
+			wantErr: "getting region availability from Linode API: random error from the API",
+		},
+		{
+			name:         "extra specs only without image nor flavor",
+			extraSpecs:   json.RawMessage(`{"disks": {"root_size": 20480}}`),
+			fromExecutor: true,
+			wantAPICalls: []string{},
+		},
+		{
+			name:         "invalid extra specs without image nor flavor",
+			extraSpecs:   json.RawMessage(`{"wait": {"poll_interval": "-5s"}}`),
+			fromExecutor: true,
+			wantErr:      "validating wait: poll_interval needs to be positive",
+			wantAPICalls: []string{},
+		},
+		{
+			name:         "VPC without subnet",
+			extraSpecs:   json.RawMessage(`{"network": {"vpc": "runners"}}`),
+			fromExecutor: true,
+			wantErr:      "getting extra specs: validating network: vpc needs a subnet",
+		},
+		{
+			name:         "invalid volume",
+			extraSpecs:   json.RawMessage(`{"volume": {"size": 5, "mount_path": "/cache"}}`),
+			fromExecutor: true,
+			wantErr:      "getting extra specs: validating volume: volume size must be between 10 and 10240 GiB",
+		},
+		{
+			name:         "invalid cache volume",
+			extraSpecs:   json.RawMessage(`{"cache_volume": {"count": 0, "size": 10, "mount_path": "/cache"}}`),
+			fromExecutor: true,
+			wantErr:      "getting extra specs: validating cache volume: cache volume count must be between 1 and 100",
+		},
+		{
+			name:         "empty region",
+			extraSpecs:   json.RawMessage(`{"regions": ["", "us-east"]}`),
+			fromExecutor: true,
+			wantErr:      "getting extra specs: regions cannot contain an empty region",
+		},
+		{
+			name:         "duplicate region",
+			extraSpecs:   json.RawMessage(`{"regions": ["us-east", "us-ord", "us-east"]}`),
+			fromExecutor: true,
+			wantErr:      "getting extra specs: region us-east is listed more than once",
+		},
+		{
+			name:         "StackScript data without StackScript",
+			extraSpecs:   json.RawMessage(`{"stackscript_data": {"docker_version": "27"}}`),
+			fromExecutor: true,
+			wantErr:      "getting extra specs: stackscript_data needs a stackscript_id",
+		},
+		{
+			name:         "firewall checked without image nor flavor",
+			extraSpecs:   json.RawMessage(`{"firewall_id": 2222}`),
+			fromExecutor: true,
+			getFirewall: func(ctx context.Context, ID int) (*linodego.Firewall, error) {
+				return nil, &linodego.Error{Code: 404, Message: "Not found"}
+			},
+			wantErr:      "firewall 2222 does not exist",
+			wantAPICalls: []string{MockGetFirewall},
+		},
+	}
 
-		err = cli.RemoveAllInstances(t.Context())
-		assert.ErrorContains(t, err, "deleting instance 1111: deleting instance from Linode API: random error from the API")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLinode{
+				calls:                 []call{},
+				getImage:              tt.getImage,
+				getType:               tt.getType,
+				getRegionAvailability: tt.getRegionAvailability,
+				getFirewall:           tt.getFirewall,
+				getPlacementGroup:     tt.getPlacementGroup,
+				getStackscript:        tt.getStackscript,
+			}
 
-		require.Len(t, m.calls, 2)
-		c := m.calls[0]
-		assert.Equal(t, c.name, MockListInstances)
+			cli, err := client.New(
+				&config.Config{
+					Token:      "foo",
+					Region:     "us-ord",
+					Regions:    tt.regions,
+					FirewallID: tt.firewallID,
+				},
+				m,
+				"1234",
+			)
+			require.NoError(t, err)
 
-		opts, ok := c.args.(*linodego.ListOptions)
-		require.True(t, ok)
-		assert.Equal(t, opts.Filter, `{"tags":"garm-controller-id=1234"}`)
+			// The v0.1.1 executor provides neither the image nor the
+			// flavor.
+			image, flavor := tt.image, "g6-standard-2"
+			if image == "" {
+				image = "linode/ubuntu24.04"
+			}
+			if tt.fromExecutor {
+				image, flavor = "", ""
+			}
 
-		c = m.calls[1]
-		assert.Equal(t, c.name, MockDeleteInstance)
+			err = cli.ValidatePoolInfo(t.Context(), image, flavor, tt.extraSpecs)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
 
-		id, ok := c.args.(int)
-		require.True(t, ok)
-		assert.Equal(t, id, 1111)
-	})
+			if tt.wantAPICalls != nil {
+				names := make([]string, len(m.calls))
+				for i, c := range m.calls {
+					names[i] = c.name
+				}
+				assert.Equal(t, names, tt.wantAPICalls)
+			}
+		})
+	}
 }
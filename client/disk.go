@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/linode/linodego"
+
+	"github.com/flatcar/garm-provider-linode/config"
+)
+
+const (
+	// defaultSwapSize is the size of the swap disk Linode creates along
+	// with the image, in MB.
+	defaultSwapSize = 512
+	// scratchDiskDevice is the device of the scratch disk, after the root
+	// and swap ones.
+	scratchDiskDevice = "/dev/sdc"
+	// scratchDiskFilesystem is the filesystem Linode formats the scratch
+	// disk with.
+	scratchDiskFilesystem = "ext4"
+	// defaultDiskKernel boots the kernel of the image, like the
+	// configuration profiles Linode creates along with the image.
+	defaultDiskKernel = "linode/grub2"
+	// flatcarDiskKernel boots the bootloader of Flatcar images, which are
+	// partitioned disks that GRUB 2 of Linode cannot boot.
+	flatcarDiskKernel = "linode/direct-disk"
+)
+
+// diskKernelRegexp matches the IDs of the kernels provided by Linode.
+var diskKernelRegexp = regexp.MustCompile(`^linode/[a-zA-Z0-9_.-]+$`)
+
+// diskSpec describes the disk layout of the runners, replacing the default
+// one of the plan: a root disk holding the image and a swap disk.
+type diskSpec struct {
+	// RootSize is the size of the root disk in MB.
+	RootSize int `json:"root_size,omitempty" jsonschema:"minimum=1,description=Size of the root disk in MB (default: the rest of the plan disk)."`
+	// SwapSize is the size of the swap disk in MB, 0 meaning no swap.
+	SwapSize *int `json:"swap_size,omitempty" jsonschema:"minimum=0,description=Size of the swap disk in MB\\, 0 meaning no swap (default: 512)."`
+	// Scratch is a disk formatted by Linode and mounted at boot.
+	Scratch *scratchDiskSpec `json:"scratch,omitempty" jsonschema:"description=Disk formatted with ext4 and mounted at boot\\, deleted with the runner."`
+	// Kernel boots the runners, like linode/grub2.
+	Kernel string `json:"kernel,omitempty" jsonschema:"pattern=^linode/[a-zA-Z0-9_.-]+$,description=Kernel booting the runners\\, like linode/grub2 or linode/latest-64bit (default: linode/direct-disk for Flatcar images and linode/grub2 otherwise)."`
+}
+
+// scratchDiskSpec describes the scratch disk of the runners.
+type scratchDiskSpec struct {
+	// Size of the disk in MB.
+	Size int `json:"size" jsonschema:"minimum=1,description=Size of the scratch disk in MB."`
+	// MountPath is where the disk is mounted on the runner.
+	MountPath string `json:"mount_path" jsonschema:"pattern=^(/[a-zA-Z0-9_.-]+)+$,description=Absolute path where the scratch disk is mounted on the runner."`
+}
+
+// validate checks the disk spec.
+func (d *diskSpec) validate() error {
+	if d.RootSize < 0 {
+		return fmt.Errorf("root disk size must not be negative")
+	}
+
+	if d.SwapSize != nil && *d.SwapSize < 0 {
+		return fmt.Errorf("swap disk size must not be negative")
+	}
+
+	if d.Kernel != "" && !diskKernelRegexp.MatchString(d.Kernel) {
+		return fmt.Errorf("kernel %q must be a Linode kernel, like linode/grub2", d.Kernel)
+	}
+
+	if d.Scratch != nil {
+		if d.Scratch.Size < 1 {
+			return fmt.Errorf("scratch disk size must be positive")
+		}
+
+		if !volumeMountPathRegexp.MatchString(d.Scratch.MountPath) || path.Clean(d.Scratch.MountPath) != d.Scratch.MountPath {
+			return fmt.Errorf("scratch disk mount path %q must be a clean absolute path", d.Scratch.MountPath)
+		}
+	}
+
+	return nil
+}
+
+// kernel returns the kernel booting the runners of the image.
+func (d *diskSpec) kernel(image string) string {
+	if d.Kernel != "" {
+		return d.Kernel
+	}
+
+	if isFlatcarImage(image) {
+		return flatcarDiskKernel
+	}
+
+	return defaultDiskKernel
+}
+
+// volumeSpec returns the scratch disk as a volume, to mount it the same way.
+func (s *scratchDiskSpec) volumeSpec() *volumeSpec {
+	return &volumeSpec{
+		Filesystem: scratchDiskFilesystem,
+		MountPath:  s.MountPath,
+	}
+}
+
+// diskLayout holds the sizes of the disks of a runner in MB, 0 meaning no
+// such disk.
+type diskLayout struct {
+	root    int
+	swap    int
+	scratch int
+}
+
+// layout returns the sizes of the disks on a plan disk of planSize MB, the
+// root disk taking what is left when its size is not set.
+func (d *diskSpec) layout(planSize int) (diskLayout, error) {
+	layout := diskLayout{
+		root: d.RootSize,
+		swap: defaultSwapSize,
+	}
+
+	if d.SwapSize != nil {
+		layout.swap = *d.SwapSize
+	}
+
+	if d.Scratch != nil {
+		layout.scratch = d.Scratch.Size
+	}
+
+	if layout.root == 0 {
+		layout.root = planSize - layout.swap - layout.scratch
+	}
+
+	if layout.root < 1 || layout.root+layout.swap+layout.scratch > planSize {
+		return diskLayout{}, fmt.Errorf("root (%d MB), swap (%d MB) and scratch (%d MB) disks do not fit on the %d MB plan disk",
+			max(layout.root, 0), layout.swap, layout.scratch, planSize)
+	}
+
+	return layout, nil
+}
+
+// getDiskLayout returns the disk layout of the runners of the flavor.
+func (c *Linode) getDiskLayout(ctx context.Context, spec *diskSpec, flavor string) (diskLayout, error) {
+	typ, err := c.api.GetType(ctx, flavor)
+	if err != nil {
+		return diskLayout{}, fmt.Errorf("getting type from Linode API: %w", err)
+	}
+
+	return spec.layout(typ.Disk)
+}
+
+// createDisks creates the disks of an instance created without any, root
+// holding the image options, and the configuration profile booting them
+// with kernel. The disks are waited for as long as the runner. The instance
+// must not be booted yet.
+func (c *Linode) createDisks(ctx context.Context, id int, layout diskLayout, root linodego.InstanceDiskCreateOptions, kernel string, interfaces []linodego.InstanceConfigInterfaceCreateOptions, wait config.Wait) (*linodego.InstanceConfig, error) {
+	root.Label = "root"
+	root.Size = layout.root
+
+	type plannedDisk struct {
+		opts   linodego.InstanceDiskCreateOptions
+		device **linodego.InstanceConfigDevice
+	}
+
+	var devices linodego.InstanceConfigDeviceMap
+	disks := []plannedDisk{
+		{opts: root, device: &devices.SDA},
+	}
+
+	if layout.swap > 0 {
+		disks = append(disks, plannedDisk{
+			opts: linodego.InstanceDiskCreateOptions{
+				Label:      "swap",
+				Size:       layout.swap,
+				Filesystem: string(linodego.FilesystemSwap),
+			},
+			device: &devices.SDB,
+		})
+	}
+
+	// The scratch disk keeps its device when there is no swap, for the
+	// user data to mount it.
+	if layout.scratch > 0 {
+		disks = append(disks, plannedDisk{
+			opts: linodego.InstanceDiskCreateOptions{
+				Label:      "scratch",
+				Size:       layout.scratch,
+				Filesystem: scratchDiskFilesystem,
+			},
+			device: &devices.SDC,
+		})
+	}
+
+	ids := make([]int, 0, len(disks))
+	for _, d := range disks {
+		disk, err := c.api.CreateInstanceDisk(ctx, id, d.opts)
+		if err != nil {
+			return nil, fmt.Errorf("creating %s disk from Linode API: %w", d.opts.Label, err)
+		}
+
+		*d.device = &linodego.InstanceConfigDevice{DiskID: disk.ID}
+		ids = append(ids, disk.ID)
+	}
+
+	if err := c.waitForDisksReady(ctx, id, ids, wait); err != nil {
+		return nil, err
+	}
+
+	cfg, err := c.api.CreateInstanceConfig(ctx, id, linodego.InstanceConfigCreateOptions{
+		Label:      "garm",
+		Devices:    devices,
+		Interfaces: interfaces,
+		Kernel:     kernel,
+		RootDevice: ptr("/dev/sda"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating configuration profile from Linode API: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// waitForDisksReady polls the disks of an instance until they are created.
+func (c *Linode) waitForDisksReady(ctx context.Context, id int, disks []int, wait config.Wait) error {
+	timeout := time.Duration(wait.Timeout)
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("time limit of %s exceeded", timeout))
+	defer cancel()
+
+	ticker := time.NewTicker(time.Duration(wait.PollInterval))
+	defer ticker.Stop()
+
+	for len(disks) > 0 {
+		disk, err := c.api.GetInstanceDisk(ctx, id, disks[0])
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("getting disk %d from Linode API: %w", disks[0], err)
+		}
+
+		if err == nil && disk.Status == linodego.DiskReady {
+			disks = disks[1:]
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for disk %d to be ready: %w", disks[0], context.Cause(ctx))
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+const (
+	// eventsPageSize is the number of recent events looked up, only the
+	// first page being fetched.
+	eventsPageSize = 100
+	// maxFaultsPerInstance bounds the number of failed events reported for
+	// an instance.
+	maxFaultsPerInstance = 5
+)
+
+// GetInstanceFaults returns a summary of the recent failed events of the
+// instances, such as a failed boot, by instance ID. Instances without any
+// failed event are left out. Tokens without access to the events are not
+// an error, no fault is reported.
+func (c *Linode) GetInstanceFaults(ctx context.Context, ids ...int) (map[int]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	entities := make([]filter, len(ids))
+	for i, id := range ids {
+		entities[i] = fieldIs("entity.id", id)
+	}
+
+	opts, err := and(fieldIs("entity.type", linodego.EntityLinode), or(entities...)).
+		orderBy("created", linodego.Descending).
+		listOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the most recent events matter.
+	opts.PageOptions = &linodego.PageOptions{Page: 1}
+	opts.PageSize = eventsPageSize
+
+	events, err := c.api.ListEvents(ctx, opts)
+	if err != nil {
+		if linodego.ErrHasStatus(err, http.StatusUnauthorized, http.StatusForbidden) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("listing events from Linode API: %w", err)
+	}
+
+	return summarizeFaults(events), nil
+}
+
+// summarizeFaults summarizes the failed events by instance ID. Events are
+// listed newest first, a failed event followed by a successful one of the
+// same action, like a boot succeeding after a failed one, is left out.
+func summarizeFaults(events []linodego.Event) map[int]string {
+	faults := map[int][]string{}
+	succeeded := map[int]map[linodego.EventAction]bool{}
+
+	for _, event := range events {
+		id, ok := eventEntityID(event.Entity)
+		if !ok {
+			continue
+		}
+
+		if succeeded[id] == nil {
+			succeeded[id] = map[linodego.EventAction]bool{}
+		}
+
+		switch event.Status {
+		case linodego.EventFinished:
+			succeeded[id][event.Action] = true
+		case linodego.EventFailed:
+			if succeeded[id][event.Action] || len(faults[id]) >= maxFaultsPerInstance {
+				continue
+			}
+
+			fault := fmt.Sprintf("%s failed", event.Action)
+			if event.Created != nil {
+				fault = fmt.Sprintf("%s: %s", event.Created.UTC().Format(time.RFC3339), fault)
+			}
+
+			if event.Message != "" {
+				fault = fmt.Sprintf("%s: %s", fault, event.Message)
+			}
+
+			faults[id] = append(faults[id], fault)
+		}
+	}
+
+	summaries := make(map[int]string, len(faults))
+	for id, f := range faults {
+		summaries[id] = strings.Join(f, "\n")
+	}
+
+	return summaries
+}
+
+// eventEntityID returns the ID of the Linode an event is about.
+func eventEntityID(entity *linodego.EventEntity) (int, bool) {
+	if entity == nil || entity.Type != linodego.EntityLinode {
+		return 0, false
+	}
+
+	switch id := entity.ID.(type) {
+	case float64:
+		return int(id), true
+	case int:
+		return id, true
+	case string:
+		i, err := strconv.Atoi(id)
+		return i, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/linode/linodego"
+)
+
+// filter is a filter of the Linode API filtering language, see
+// https://techdocs.akamai.com/linode-api/reference/filtering-and-sorting.
+type filter map[string]any
+
+// and matches the items matching all the filters.
+func and(filters ...filter) filter {
+	return filter{"+and": filters}
+}
+
+// or matches the items matching any of the filters.
+func or(filters ...filter) filter {
+	return filter{"+or": filters}
+}
+
+// fieldIs matches the items whose field is equal to value.
+func fieldIs(field string, value any) filter {
+	return filter{field: value}
+}
+
+// labelIs matches the items labelled label.
+func labelIs(label string) filter {
+	return fieldIs("label", label)
+}
+
+// hasTag matches the items tagged with tag. The API matches tags loosely,
+// the items have to be checked with hasTags.
+func hasTag(tag string) filter {
+	return fieldIs("tags", tag)
+}
+
+// orderBy returns the filter sorting the items by field, in order
+// (linodego.Ascending or linodego.Descending).
+func (f filter) orderBy(field, order string) filter {
+	sorted := maps.Clone(f)
+	sorted["+order_by"] = field
+	sorted["+order"] = order
+
+	return sorted
+}
+
+// listOptions returns the options to list all the pages of items matching
+// the filter.
+func (f filter) listOptions() (*linodego.ListOptions, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling filter: %w", err)
+	}
+
+	return &linodego.ListOptions{
+		Filter: string(b),
+	}, nil
+}
+
+// hasTags returns true if tags contains all the wanted tags.
+func hasTags(tags []string, want ...string) bool {
+	for _, tag := range want {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// poolTag returns the tag of the instances of a pool.
+func poolTag(poolID string) string {
+	return fmt.Sprintf("%s=%s", TagPool, poolID)
+}
+
+// controllerTag returns the tag of the resources owned by this controller.
+func (c *Linode) controllerTag() string {
+	return fmt.Sprintf("%s=%s", TagController, c.id)
+}
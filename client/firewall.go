@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/linode/linodego"
+)
+
+// firewallLabelMaxLength is the maximum length of a Cloud Firewall label.
+const firewallLabelMaxLength = 32
+
+// getFirewallID returns the ID of the Cloud Firewall to assign to a runner,
+// 0 meaning no firewall. The pool firewall takes precedence over the
+// provider one, itself taking precedence over the managed firewall.
+func (c *Linode) getFirewallID(ctx context.Context, spec extraSpecs) (int, error) {
+	if spec.FirewallID != 0 {
+		return spec.FirewallID, nil
+	}
+
+	if c.config.FirewallID != 0 {
+		return c.config.FirewallID, nil
+	}
+
+	if !c.config.ManagedFirewall {
+		return 0, nil
+	}
+
+	firewall, err := c.ensureManagedFirewall(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting managed firewall: %w", err)
+	}
+
+	return firewall.ID, nil
+}
+
+// ensureManagedFirewall returns the firewall owned by this controller,
+// creating it if it does not exist yet. It drops all inbound traffic.
+func (c *Linode) ensureManagedFirewall(ctx context.Context) (*linodego.Firewall, error) {
+	firewalls, err := c.listManagedFirewalls(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(firewalls) > 0 {
+		return &firewalls[0], nil
+	}
+
+	label := fmt.Sprintf("garm-%s", c.id)
+	if len(label) > firewallLabelMaxLength {
+		label = label[:firewallLabelMaxLength]
+	}
+
+	firewall, err := c.api.CreateFirewall(ctx, linodego.FirewallCreateOptions{
+		Label: label,
+		Rules: linodego.FirewallRuleSet{
+			Inbound:        []linodego.FirewallRule{},
+			InboundPolicy:  "DROP",
+			Outbound:       []linodego.FirewallRule{},
+			OutboundPolicy: "ACCEPT",
+		},
+		Tags: []string{
+			c.controllerTag(),
+		},
+	})
+	if err != nil {
+		// Another provider invocation may have created it in the meantime.
+		if firewalls, lErr := c.listManagedFirewalls(ctx); lErr == nil && len(firewalls) > 0 {
+			return &firewalls[0], nil
+		}
+
+		return nil, fmt.Errorf("creating firewall from Linode API: %w", err)
+	}
+
+	return firewall, nil
+}
+
+// listManagedFirewalls returns the firewalls owned by this controller.
+func (c *Linode) listManagedFirewalls(ctx context.Context) ([]linodego.Firewall, error) {
+	opts, err := hasTag(c.controllerTag()).listOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	firewalls, err := c.api.ListFirewalls(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing firewalls from Linode API: %w", err)
+	}
+
+	return slices.DeleteFunc(firewalls, func(f linodego.Firewall) bool {
+		return !hasTags(f.Tags, c.controllerTag())
+	}), nil
+}
+
+// removeManagedFirewalls deletes the firewalls owned by this controller.
+func (c *Linode) removeManagedFirewalls(ctx context.Context) error {
+	firewalls, err := c.listManagedFirewalls(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, firewall := range firewalls {
+		if err := c.api.DeleteFirewall(ctx, firewall.ID); err != nil {
+			return fmt.Errorf("deleting firewall %d from Linode API: %w", firewall.ID, err)
+		}
+	}
+
+	return nil
+}
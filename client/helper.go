@@ -3,19 +3,65 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/cloudbase/garm-provider-common/cloudconfig"
 	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/invopop/jsonschema"
+	"github.com/linode/linodego"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/flatcar/garm-provider-linode/config"
+)
+
+const (
+	userDataCloudInit = "cloud-init"
+	userDataIgnition  = "ignition"
+
+	defaultWaitTimeout      = 5 * time.Minute
+	defaultWaitPollInterval = 5 * time.Second
 )
 
 type extraSpecs struct {
 	//ExtraPackages to install on the VM.
 	ExtraPackages []string `json:"extra_packages,omitempty" jsonschema:"description=Extra packages to install on the VM."`
+	// FirewallID overrides the Cloud Firewall from the provider config.
+	FirewallID int `json:"firewall_id,omitempty" jsonschema:"minimum=1,description=ID of the Cloud Firewall assigned to the runners (overrides the provider config)."`
+	// Region overrides the region from the provider config.
+	Region string `json:"region,omitempty" jsonschema:"description=Region where to deploy the runners (overrides the provider config)."`
+	// Regions is an ordered list of regions, the next one being tried
+	// when Linode lacks capacity or the VPC is in another region. It
+	// takes precedence over Region.
+	Regions []string `json:"regions,omitempty" jsonschema:"minLength=1,uniqueItems=true,description=Ordered list of regions where to deploy the runners. The next region is tried when Linode lacks capacity or the VPC is in another region (overrides region)."`
+	// StackScriptID is the StackScript the runners are deployed with.
+	StackScriptID int `json:"stackscript_id,omitempty" jsonschema:"minimum=1,description=ID of the StackScript the runners are deployed with."`
+	// StackScriptData are the user defined fields of the StackScript.
+	StackScriptData map[string]string `json:"stackscript_data,omitempty" jsonschema:"description=User defined fields of the StackScript (the garm_* fields are set by the provider)."`
+	// UserDataFormat forces the user data format, it is otherwise
+	// detected from the image.
+	UserDataFormat string `json:"user_data_format,omitempty" jsonschema:"enum=cloud-init,enum=ignition,description=Format of the user data (default: ignition for Flatcar images and cloud-init otherwise)."`
+	// Network overrides the network interfaces from the provider config.
+	Network *config.Network `json:"network,omitempty" jsonschema:"description=Network interfaces of the runners (overrides the provider config)."`
+	// Wait overrides how the runners are waited for once created.
+	Wait *config.Wait `json:"wait,omitempty" jsonschema:"description=How the runners are waited for once created (overrides the provider config)."`
+	// PlacementGroup overrides the placement group from the provider config.
+	PlacementGroup *config.PlacementGroup `json:"placement_group,omitempty" jsonschema:"description=Placement group of the runners (overrides the provider config)."`
+	// Volume is a Block Storage volume created for every runner.
+	Volume *volumeSpec `json:"volume,omitempty" jsonschema:"description=Block Storage volume created for every runner\\, mounted at boot and deleted with the runner."`
+	// CacheVolume is a pool of Block Storage volumes shared by the runners.
+	CacheVolume *cacheVolumeSpec `json:"cache_volume,omitempty" jsonschema:"description=Persistent Block Storage volumes of the pool\\, each runner claiming a free one and releasing it once deleted."`
+	// Disks replaces the default disk layout of the plan.
+	Disks *diskSpec `json:"disks,omitempty" jsonschema:"description=Disk layout of the runners\\, replacing the default one of the plan."`
 	// The Cloudconfig struct from common package
 	cloudconfig.CloudConfigSpec
 }
@@ -33,39 +79,297 @@ func createRandomRootPassword() (string, error) {
 	return rootPass, nil
 }
 
-// waitUntilReady tests the checkFunction until it succeeds.
-func waitUntilReady(timeout, delay time.Duration, checkFunction func() (bool, error)) error {
-	after := time.After(timeout)
+// waitForStatus polls the instance until it reaches the status. It gives up
+// once the timeout expires or the context is canceled, reporting the last
+// status of the instance.
+func (c *Linode) waitForStatus(ctx context.Context, id int, status linodego.InstanceStatus, timeout, interval time.Duration) (*linodego.Instance, error) {
+	return c.waitForStatuses(ctx, id, []linodego.InstanceStatus{status}, timeout, interval)
+}
+
+// waitForSettled polls the instance until it is running or offline, Linode
+// refusing to boot or to shut down an instance which is busy booting,
+// shutting down, migrating, etc.
+func (c *Linode) waitForSettled(ctx context.Context, id int, timeout, interval time.Duration) (*linodego.Instance, error) {
+	return c.waitForStatuses(ctx, id, []linodego.InstanceStatus{linodego.InstanceRunning, linodego.InstanceOffline}, timeout, interval)
+}
+
+// waitForStatuses polls the instance until it reaches one of the statuses.
+func (c *Linode) waitForStatuses(ctx context.Context, id int, statuses []linodego.InstanceStatus, timeout, interval time.Duration) (*linodego.Instance, error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("time limit of %s exceeded", timeout))
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := "unknown"
 	for {
+		instance, err := c.api.GetInstance(ctx, id)
+		if err != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("getting instance: %w", err)
+		}
+
+		if err == nil {
+			if slices.Contains(statuses, instance.Status) {
+				return instance, nil
+			}
+
+			last = string(instance.Status)
+		}
+
 		select {
-		case <-after:
-			return fmt.Errorf("time limit exceeded")
-		default:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (last status: %s)", context.Cause(ctx), last)
+		case <-ticker.C:
+		}
+	}
+}
+
+// getWait returns how to wait for the runners, the pool settings taking
+// precedence over the provider ones.
+func (c *Linode) getWait(spec extraSpecs) config.Wait {
+	wait := config.Wait{
+		Timeout:      config.Duration(defaultWaitTimeout),
+		PollInterval: config.Duration(defaultWaitPollInterval),
+		Until:        config.WaitUntilRunning,
+	}
+
+	for _, w := range []*config.Wait{c.config.Wait, spec.Wait} {
+		if w == nil {
+			continue
 		}
 
-		done, err := checkFunction()
-		if err != nil {
-			return err
+		if w.Timeout != 0 {
+			wait.Timeout = w.Timeout
 		}
 
-		if done {
-			break
+		if w.PollInterval != 0 {
+			wait.PollInterval = w.PollInterval
 		}
 
-		time.Sleep(delay)
+		if w.Until != "" {
+			wait.Until = w.Until
+		}
 	}
-	return nil
+
+	return wait
+}
+
+// getRegions returns the ordered list of regions to deploy the runners to.
+func (c *Linode) getRegions(spec extraSpecs) []string {
+	switch {
+	case len(spec.Regions) > 0:
+		return spec.Regions
+	case spec.Region != "":
+		return []string{spec.Region}
+	default:
+		return c.config.GetRegions()
+	}
+}
+
+// isCapacityError returns true if the instance creation failed because
+// Linode cannot host it in the region, in which case another region may.
+func isCapacityError(err error) bool {
+	if linodego.ErrHasStatus(err, http.StatusServiceUnavailable) {
+		return true
+	}
+
+	if !linodego.ErrHasStatus(err, http.StatusBadRequest) {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	return strings.Contains(msg, "capacity") || strings.Contains(msg, "not available") || strings.Contains(msg, "unavailable")
+}
+
+// userDataFormat returns the user data format to use for the image.
+func (e extraSpecs) userDataFormat(image string) string {
+	if e.UserDataFormat != "" {
+		return e.UserDataFormat
+	}
+
+	if isFlatcarImage(image) {
+		return userDataIgnition
+	}
+
+	return userDataCloudInit
+}
+
+// preInstallScriptNames returns the names of the pre-install scripts in the
+// order they run, alphabetical for both cloud-init and Ignition.
+func (e extraSpecs) preInstallScriptNames() []string {
+	return slices.Sorted(maps.Keys(e.PreInstallScripts))
+}
+
+// isFlatcarImage returns true for the Flatcar Container Linux images, public
+// or private ones named after it.
+func isFlatcarImage(image string) bool {
+	return strings.Contains(strings.ToLower(image), "flatcar")
 }
 
 func extraSpecsFromBootstrapData(data params.BootstrapInstance) (extraSpecs, error) {
-	if len(data.ExtraSpecs) == 0 {
+	return parseExtraSpecs(data.ExtraSpecs)
+}
+
+// parseExtraSpecs parses and validates the extra specs, the only place they
+// are read from. Unknown keys are rejected rather than silently ignored.
+func parseExtraSpecs(data json.RawMessage) (extraSpecs, error) {
+	if len(data) == 0 {
 		return extraSpecs{}, nil
 	}
 
+	if err := checkBase64Specs(data); err != nil {
+		return extraSpecs{}, err
+	}
+
 	var spec extraSpecs
-	if err := json.Unmarshal(data.ExtraSpecs, &spec); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
 		return extraSpecs{}, fmt.Errorf("unmarshalling extra_specs: %w", err)
 	}
 
+	if err := spec.validate(); err != nil {
+		return extraSpecs{}, err
+	}
+
 	return spec, nil
 }
+
+// validate checks the extra specs, for a pool to be rejected when it is
+// validated rather than failing to create every runner. They are not
+// validated against their JSON schema when creating instances, the checks
+// cannot rely on it.
+func (e extraSpecs) validate() error {
+	if e.FirewallID < 0 {
+		return fmt.Errorf("firewall_id needs to be a positive integer")
+	}
+
+	if err := config.ValidateRegions(e.Regions); err != nil {
+		return err
+	}
+
+	if e.StackScriptID < 0 {
+		return fmt.Errorf("stackscript_id needs to be a positive integer")
+	}
+
+	if e.StackScriptID == 0 && len(e.StackScriptData) > 0 {
+		return fmt.Errorf("stackscript_data needs a stackscript_id")
+	}
+
+	// An unknown format would otherwise silently fall back to cloud-init.
+	if e.UserDataFormat != "" && e.UserDataFormat != userDataCloudInit && e.UserDataFormat != userDataIgnition {
+		return fmt.Errorf("user_data_format needs to be %s or %s", userDataCloudInit, userDataIgnition)
+	}
+
+	if e.Network != nil {
+		if err := e.Network.Validate(); err != nil {
+			return fmt.Errorf("validating network: %w", err)
+		}
+	}
+
+	if e.Wait != nil {
+		if err := e.Wait.Validate(); err != nil {
+			return fmt.Errorf("validating wait: %w", err)
+		}
+	}
+
+	if e.PlacementGroup != nil {
+		if err := e.PlacementGroup.Validate(); err != nil {
+			return fmt.Errorf("validating placement group: %w", err)
+		}
+	}
+
+	if e.Volume != nil {
+		if err := e.Volume.validate(); err != nil {
+			return fmt.Errorf("validating volume: %w", err)
+		}
+	}
+
+	if e.CacheVolume != nil {
+		if err := e.CacheVolume.validate(); err != nil {
+			return fmt.Errorf("validating cache volume: %w", err)
+		}
+	}
+
+	if e.Disks != nil {
+		if err := e.Disks.validate(); err != nil {
+			return fmt.Errorf("validating disks: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkBase64Specs checks the base64 encoded extra specs, which the JSON
+// decoder fails to decode without telling which one is invalid.
+func checkBase64Specs(data json.RawMessage) error {
+	var spec struct {
+		RunnerInstallTemplate string            `json:"runner_install_template"`
+		PreInstallScripts     map[string]string `json:"pre_install_scripts"`
+	}
+
+	// Any other error is reported when decoding the extra specs.
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(spec.RunnerInstallTemplate); err != nil {
+		return fmt.Errorf("runner_install_template is not valid base64: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(spec.PreInstallScripts)) {
+		if _, err := base64.StdEncoding.DecodeString(spec.PreInstallScripts[name]); err != nil {
+			return fmt.Errorf("pre_install_scripts: %s is not valid base64: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ExtraSpecsJSONSchema returns the JSON schema of the extra specs
+// supported by the provider.
+func ExtraSpecsJSONSchema() (string, error) {
+	reflector := jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		DoNotReference:            true,
+	}
+
+	schema, err := json.MarshalIndent(reflector.Reflect(&extraSpecs{}), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshalling schema: %w", err)
+	}
+
+	return string(schema), nil
+}
+
+// ValidateExtraSpecs validates the pool extra specs against their JSON schema.
+func ValidateExtraSpecs(data json.RawMessage) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	schema, err := ExtraSpecsJSONSchema()
+	if err != nil {
+		return fmt.Errorf("getting extra specs schema: %w", err)
+	}
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewStringLoader(schema),
+		gojsonschema.NewBytesLoader(data),
+	)
+	if err != nil {
+		return fmt.Errorf("validating extra specs: %w", err)
+	}
+
+	if !result.Valid() {
+		errs := make([]string, len(result.Errors()))
+		for i, e := range result.Errors() {
+			errs[i] = e.String()
+		}
+
+		return fmt.Errorf("invalid extra specs: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
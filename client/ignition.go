@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/cloudbase/garm-provider-common/defaults"
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/cloudbase/garm-provider-common/util"
+	"github.com/coreos/ignition/v2/config/v3_4/types"
+)
+
+const (
+	// ignitionRunnerDir holds the install and pre-install scripts until
+	// the runner is installed.
+	ignitionRunnerDir = "/opt/garm"
+	// ignitionCACertPath is picked up by update-ca-certificates on Flatcar.
+	ignitionCACertPath = "/etc/ssl/certs/garm-ca.pem"
+	// ignitionUnitName is the systemd unit installing the runner.
+	ignitionUnitName = "garm-runner-install.service"
+	// ignitionDataURLPrefix prefixes the contents of the files embedded in
+	// an Ignition config.
+	ignitionDataURLPrefix = "data:;base64,"
+)
+
+var ignitionUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Install the GARM runner
+Wants=network-online.target
+After=network-online.target
+ConditionPathExists={{ .InstallScript }}
+{{- range .MountPaths }}
+RequiresMountsFor={{ . }}
+{{- end }}
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+{{- if .CACert }}
+ExecStartPre=/usr/sbin/update-ca-certificates
+{{- end }}
+{{- range .PreInstallScripts }}
+ExecStartPre={{ . }}
+{{- end }}
+ExecStart=/usr/bin/su -l -c {{ .InstallScript }} {{ .User }}
+ExecStartPost=/usr/bin/rm -rf {{ .Dir }}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+var ignitionMountTemplate = template.Must(template.New("mount").Parse(`[Unit]
+Description=Mount {{ .MountPath }} for the runner
+Before=local-fs.target
+
+[Mount]
+What={{ .Device }}
+Where={{ .MountPath }}
+Type={{ .Filesystem }}
+
+[Install]
+RequiredBy=local-fs.target
+`))
+
+// getIgnitionConfig returns an Ignition config setting up the runner on
+// Flatcar Container Linux. It carries the same content as the cloud-init
+// config generated by cloudconfig.GetCloudInitConfig: the runner user with
+// its SSH keys, the CA bundle, the pre-install scripts and the runner install
+// script, the latter being run by a systemd unit.
+func getIgnitionConfig(bootstrapParams params.BootstrapInstance, installScript []byte, spec extraSpecs) (types.Config, error) {
+	if bootstrapParams.OSType != params.Linux {
+		return types.Config{}, fmt.Errorf("ignition is not supported on %s", bootstrapParams.OSType)
+	}
+
+	if len(spec.ExtraPackages) > 0 {
+		return types.Config{}, fmt.Errorf("extra_packages is not supported with ignition")
+	}
+
+	cfg := types.Config{
+		Ignition: types.Ignition{
+			Version: types.MaxVersion.String(),
+		},
+	}
+
+	keys := make([]types.SSHAuthorizedKey, len(bootstrapParams.SSHKeys))
+	for i, key := range bootstrapParams.SSHKeys {
+		keys[i] = types.SSHAuthorizedKey(key)
+	}
+
+	cfg.Passwd.Users = []types.PasswdUser{
+		{
+			Name:              defaults.DefaultUser,
+			HomeDir:           ptr(fmt.Sprintf("/home/%s", defaults.DefaultUser)),
+			Shell:             ptr(defaults.DefaultUserShell),
+			Groups:            []types.Group{"sudo", "docker"},
+			SSHAuthorizedKeys: keys,
+		},
+	}
+
+	unit := struct {
+		CACert            bool
+		Dir               string
+		InstallScript     string
+		MountPaths        []string
+		PreInstallScripts []string
+		User              string
+	}{
+		Dir:           ignitionRunnerDir,
+		InstallScript: path.Join(ignitionRunnerDir, "install_runner.sh"),
+		User:          defaults.DefaultUser,
+	}
+
+	cfg.Storage.Files = append(cfg.Storage.Files,
+		ignitionFile(fmt.Sprintf("/etc/sudoers.d/%s", defaults.DefaultUser), []byte(fmt.Sprintf("%s ALL=(ALL) NOPASSWD:ALL\n", defaults.DefaultUser)), 0o440),
+	)
+
+	if len(bootstrapParams.CACertBundle) > 0 {
+		if ok := x509.NewCertPool().AppendCertsFromPEM(bootstrapParams.CACertBundle); !ok {
+			return types.Config{}, fmt.Errorf("failed to parse CA cert bundle")
+		}
+
+		cfg.Storage.Files = append(cfg.Storage.Files, ignitionFile(ignitionCACertPath, bootstrapParams.CACertBundle, 0o644))
+		unit.CACert = true
+	}
+
+	// The cache volume the runner claims is not known yet, it is mounted
+	// by a script run before the pre-install scripts.
+	if spec.CacheVolume != nil {
+		script, err := cacheVolumeMountScript(bootstrapParams.PoolID, spec.CacheVolume)
+		if err != nil {
+			return types.Config{}, err
+		}
+
+		p := path.Join(ignitionRunnerDir, script.name)
+		cfg.Storage.Files = append(cfg.Storage.Files, ignitionFile(p, script.contents, 0o755))
+		unit.PreInstallScripts = append(unit.PreInstallScripts, p)
+	}
+
+	for _, name := range spec.preInstallScriptNames() {
+		p := path.Join(ignitionRunnerDir, "pre-install", name)
+		cfg.Storage.Files = append(cfg.Storage.Files, ignitionFile(p, spec.PreInstallScripts[name], 0o755))
+		unit.PreInstallScripts = append(unit.PreInstallScripts, p)
+	}
+
+	cfg.Storage.Files = append(cfg.Storage.Files, ignitionFile(unit.InstallScript, installScript, 0o755))
+
+	// The volume is attached and the scratch disk created before the
+	// instance boots, Ignition formats them unless they already hold the
+	// filesystem and systemd mounts them.
+	var mounts []ignitionMount
+	if spec.Volume != nil {
+		mounts = append(mounts, ignitionMount{device: volumeDevice(bootstrapParams.Name), spec: spec.Volume})
+	}
+
+	if spec.Disks != nil && spec.Disks.Scratch != nil {
+		mounts = append(mounts, ignitionMount{device: scratchDiskDevice, spec: spec.Disks.Scratch.volumeSpec()})
+	}
+
+	for _, m := range mounts {
+		cfg.Storage.Filesystems = append(cfg.Storage.Filesystems, types.Filesystem{
+			Device:         m.device,
+			Format:         ptr(m.spec.filesystem()),
+			WipeFilesystem: ptr(false),
+		})
+
+		var mount bytes.Buffer
+		err := ignitionMountTemplate.Execute(&mount, struct {
+			Device     string
+			Filesystem string
+			MountPath  string
+		}{
+			Device:     m.device,
+			Filesystem: m.spec.filesystem(),
+			MountPath:  m.spec.MountPath,
+		})
+		if err != nil {
+			return types.Config{}, fmt.Errorf("rendering systemd mount unit: %w", err)
+		}
+
+		cfg.Systemd.Units = append(cfg.Systemd.Units, types.Unit{
+			Name:     mountUnitName(m.spec.MountPath),
+			Enabled:  ptr(true),
+			Contents: ptr(mount.String()),
+		})
+		unit.MountPaths = append(unit.MountPaths, m.spec.MountPath)
+	}
+
+	var contents bytes.Buffer
+	if err := ignitionUnitTemplate.Execute(&contents, unit); err != nil {
+		return types.Config{}, fmt.Errorf("rendering systemd unit: %w", err)
+	}
+
+	cfg.Systemd.Units = append(cfg.Systemd.Units, types.Unit{
+		Name:     ignitionUnitName,
+		Enabled:  ptr(true),
+		Contents: ptr(contents.String()),
+	})
+
+	return cfg, nil
+}
+
+// ignitionMount is a block device Ignition formats and systemd mounts.
+type ignitionMount struct {
+	device string
+	spec   *volumeSpec
+}
+
+// compressIgnitionFiles gzip compresses the contents of the files embedded
+// in an Ignition config, which Ignition decompresses when writing them.
+func compressIgnitionFiles(cfg *types.Config) error {
+	for i, file := range cfg.Storage.Files {
+		if file.Contents.Source == nil || !strings.HasPrefix(*file.Contents.Source, ignitionDataURLPrefix) {
+			continue
+		}
+
+		contents, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*file.Contents.Source, ignitionDataURLPrefix))
+		if err != nil {
+			return fmt.Errorf("decoding %s contents: %w", file.Path, err)
+		}
+
+		compressed, err := util.CompressData(contents)
+		if err != nil {
+			return fmt.Errorf("compressing %s contents: %w", file.Path, err)
+		}
+
+		cfg.Storage.Files[i].Contents = types.Resource{
+			Source:      ptr(ignitionDataURLPrefix + base64.StdEncoding.EncodeToString(compressed)),
+			Compression: ptr("gzip"),
+		}
+	}
+
+	return nil
+}
+
+// ignitionFile returns a root owned file embedding its contents as a data URL.
+func ignitionFile(p string, contents []byte, mode int) types.File {
+	return types.File{
+		Node: types.Node{
+			Path:      p,
+			Overwrite: ptr(true),
+		},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.Resource{
+				Source: ptr(ignitionDataURLPrefix + base64.StdEncoding.EncodeToString(contents)),
+			},
+			Mode: ptr(mode),
+		},
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/linode/linodego"
+
+	"github.com/flatcar/garm-provider-linode/config"
+)
+
+// nat1To1Any asks Linode to pick the public IPv4 address of the instance
+// for the VPC 1:1 NAT.
+const nat1To1Any = "any"
+
+// errNetworkUnavailable is returned when the VPC or its subnet does not
+// exist in a region, another region may have them.
+var errNetworkUnavailable = errors.New("network unavailable")
+
+// getInterfaces returns the network interfaces to create the instance with.
+// A nil slice lets Linode attach the default public interface. An error
+// matching errNetworkUnavailable is returned when the VPC or its subnet is
+// not in the region.
+func (c *Linode) getInterfaces(ctx context.Context, network *config.Network, region string) ([]linodego.InstanceConfigInterfaceCreateOptions, error) {
+	if network == nil {
+		return nil, nil
+	}
+
+	var interfaces []linodego.InstanceConfigInterfaceCreateOptions
+
+	// The first interface is the primary one, holding the default route.
+	if network.Public || network.VPC == "" {
+		interfaces = append(interfaces, linodego.InstanceConfigInterfaceCreateOptions{
+			Purpose: linodego.InterfacePurposePublic,
+		})
+	}
+
+	if network.VPC != "" {
+		vpc, err := c.getVPC(ctx, network.VPC)
+		if err != nil {
+			return nil, fmt.Errorf("getting VPC: %w", err)
+		}
+
+		if vpc.Region != region {
+			return nil, fmt.Errorf("%w: VPC %s is in %s, not in %s", errNetworkUnavailable, network.VPC, vpc.Region, region)
+		}
+
+		subnet, err := getVPCSubnet(vpc, network.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errNetworkUnavailable, err)
+		}
+
+		iface := linodego.InstanceConfigInterfaceCreateOptions{
+			Purpose:  linodego.InterfacePurposeVPC,
+			SubnetID: &subnet.ID,
+		}
+
+		if network.NAT1To1 {
+			nat := nat1To1Any
+			iface.IPv4 = &linodego.VPCIPv4{
+				NAT1To1: &nat,
+			}
+		}
+
+		interfaces = append(interfaces, iface)
+	}
+
+	for _, vlan := range network.VLANs {
+		interfaces = append(interfaces, linodego.InstanceConfigInterfaceCreateOptions{
+			Purpose: linodego.InterfacePurposeVLAN,
+			Label:   vlan.Label,
+		})
+	}
+
+	interfaces[0].Primary = true
+
+	return interfaces, nil
+}
+
+// getVPC returns a VPC from its ID or its label.
+func (c *Linode) getVPC(ctx context.Context, vpc string) (*linodego.VPC, error) {
+	if id, err := strconv.Atoi(vpc); err == nil {
+		v, err := c.api.GetVPC(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("getting VPC from Linode API: %w", err)
+		}
+
+		return v, nil
+	}
+
+	opts, err := labelIs(vpc).listOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	vpcs, err := c.api.ListVPCs(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing VPCs from the API: %w", err)
+	}
+
+	// VPC labels are unique within an account.
+	i := slices.IndexFunc(vpcs, func(v linodego.VPC) bool {
+		return v.Label == vpc
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("no VPC matching this label: %s", vpc)
+	}
+
+	return &vpcs[i], nil
+}
+
+// getVPCSubnet returns a subnet of the VPC from its ID or its label.
+func getVPCSubnet(vpc *linodego.VPC, subnet string) (*linodego.VPCSubnet, error) {
+	for _, s := range vpc.Subnets {
+		if s.Label == subnet || strconv.Itoa(s.ID) == subnet {
+			return &s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no subnet %s in VPC %s", subnet, vpc.Label)
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/linode/linodego"
+)
+
+const (
+	// publicImagePrefix prefixes the ID of the images provided by Linode.
+	publicImagePrefix = "linode/"
+	// privateImagePrefix prefixes the ID of the images of the account.
+	privateImagePrefix = "private/"
+)
+
+// flatcarChannels are the Flatcar Container Linux release channels, used as
+// version as the images follow a channel rather than a release.
+var flatcarChannels = []string{"alpha", "beta", "stable", "lts"}
+
+// InstanceOS describes the operating system of an instance.
+type InstanceOS struct {
+	Type    params.OSType
+	Name    string
+	Version string
+	Arch    params.OSArch
+}
+
+// GetInstanceOS returns the operating system of the instance, resolved from
+// its image. An image which no longer exists is resolved from its ID only.
+// When the image cannot be looked up, the OS is still returned along with the
+// error, without its name and version.
+func (c *Linode) GetInstanceOS(ctx context.Context, instance *linodego.Instance) (InstanceOS, error) {
+	// Neither the Linode images nor the plans report an architecture, the
+	// OS is assumed to be Linux on x86-64 as Linode only offers Linux
+	// images and x86-64 plans. This has to be revisited if ARM plans come.
+	instanceOS := InstanceOS{
+		Type: params.Linux,
+		Arch: params.Amd64,
+	}
+
+	id := instanceImage(instance)
+	if id == "" {
+		return instanceOS, nil
+	}
+
+	image, err := c.api.GetImage(ctx, id)
+	if err != nil {
+		if !linodego.IsNotFound(err) {
+			return instanceOS, fmt.Errorf("getting image from Linode API: %w", err)
+		}
+
+		image = &linodego.Image{ID: id}
+	}
+
+	instanceOS.Name, instanceOS.Version = imageOSNameVersion(image)
+
+	return instanceOS, nil
+}
+
+// imageTag returns the tag holding the image of an instance.
+func imageTag(image string) string {
+	return fmt.Sprintf("%s=%s", TagImage, image)
+}
+
+// instanceImage returns the image of an instance, falling back to its image
+// tag when it was deployed on custom disks.
+func instanceImage(instance *linodego.Instance) string {
+	if instance.Image != "" {
+		return instance.Image
+	}
+
+	for _, tag := range instance.Tags {
+		if image, ok := strings.CutPrefix(tag, TagImage+"="); ok {
+			return image
+		}
+	}
+
+	return ""
+}
+
+// checkImageOwner checks that a private image belongs to the account, rather
+// than being shared with it by another account.
+func checkImageOwner(image *linodego.Image) error {
+	if image.IsPublic || image.ImageSharing.SharedBy != nil {
+		return fmt.Errorf("image %s does not belong to the account", image.ID)
+	}
+
+	return nil
+}
+
+// imageOSNameVersion returns the OS name and version of an image. Public
+// image IDs look like linode/ubuntu24.04, while private images are only
+// described by their label, like "Ubuntu 24.04 runner".
+func imageOSNameVersion(image *linodego.Image) (string, string) {
+	var name, version string
+	if id, ok := strings.CutPrefix(image.ID, publicImagePrefix); ok {
+		i := strings.IndexFunc(id, unicode.IsDigit)
+		if i < 0 {
+			i = len(id)
+		}
+
+		name, version = strings.TrimRight(id[:i], "-_."), id[i:]
+	} else {
+		for _, field := range labelFields(image.Label) {
+			switch {
+			case name == "" && unicode.IsLetter(rune(field[0])):
+				name = field
+			case version == "" && unicode.IsDigit(rune(field[0])):
+				version = field
+			}
+		}
+	}
+
+	if name == "flatcar" {
+		version = "stable"
+		fields := labelFields(image.ID + " " + image.Label)
+		for _, channel := range flatcarChannels {
+			if slices.Contains(fields, channel) {
+				version = channel
+				break
+			}
+		}
+	}
+
+	return name, version
+}
+
+// labelFields splits a label into lower case words and version numbers.
+func labelFields(label string) []string {
+	return strings.FieldsFunc(strings.ToLower(label), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+}
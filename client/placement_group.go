@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/linode/linodego"
+
+	"github.com/flatcar/garm-provider-linode/config"
+)
+
+// errPlacementGroupUnavailable is returned when the placement group cannot
+// host a runner in a region, another region may.
+var errPlacementGroupUnavailable = errors.New("placement group unavailable")
+
+// getPlacementGroup returns the placement group options of a runner created
+// in the region, nil meaning no placement group. When the group is full or
+// in another region, the runner is created ungrouped unless the fallback is
+// to fail, an error matching errPlacementGroupUnavailable being returned.
+func (c *Linode) getPlacementGroup(ctx context.Context, spec *config.PlacementGroup, poolID, region string) (*linodego.InstanceCreatePlacementGroupOptions, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	var (
+		group *linodego.PlacementGroup
+		err   error
+	)
+	if spec.ID != 0 {
+		group, err = c.api.GetPlacementGroup(ctx, spec.ID)
+		if err != nil {
+			return nil, fmt.Errorf("getting placement group from Linode API: %w", err)
+		}
+	} else {
+		group, err = c.ensureManagedPlacementGroup(ctx, poolID, region, spec.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("getting managed placement group: %w", err)
+		}
+	}
+
+	if err := c.checkPlacementGroup(ctx, group, region); err != nil {
+		if errors.Is(err, errPlacementGroupUnavailable) && placementGroupFallback(spec) == config.PlacementGroupFallbackUngrouped {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &linodego.InstanceCreatePlacementGroupOptions{ID: group.ID}, nil
+}
+
+// placementGroupFallback returns what happens when the placement group
+// cannot host a runner.
+func placementGroupFallback(spec *config.PlacementGroup) string {
+	if spec == nil || spec.Fallback == "" {
+		return config.PlacementGroupFallbackUngrouped
+	}
+
+	return spec.Fallback
+}
+
+// checkPlacementGroup checks that the placement group can host one more
+// runner in the region, the number of members of a group being limited.
+func (c *Linode) checkPlacementGroup(ctx context.Context, group *linodego.PlacementGroup, region string) error {
+	if group.Region != region {
+		return fmt.Errorf("%w: %d is in %s", errPlacementGroupUnavailable, group.ID, group.Region)
+	}
+
+	r, err := c.api.GetRegion(ctx, region)
+	if err != nil {
+		return fmt.Errorf("getting region from Linode API: %w", err)
+	}
+
+	if limits := r.PlacementGroupLimits; limits != nil && limits.MaximumLinodesPerPG > 0 && len(group.Members) >= limits.MaximumLinodesPerPG {
+		return fmt.Errorf("%w: %d is full (%d instances)", errPlacementGroupUnavailable, group.ID, len(group.Members))
+	}
+
+	return nil
+}
+
+// isPlacementGroupError returns true if the instance creation failed because
+// of its placement group, like a strict group unable to stay compliant.
+func isPlacementGroupError(err error) bool {
+	return linodego.ErrHasStatus(err, http.StatusBadRequest) &&
+		strings.Contains(strings.ToLower(err.Error()), "placement group")
+}
+
+// placementGroupLabelPrefix returns the prefix of the labels of the placement
+// groups managed by this controller. Placement groups cannot be tagged, they
+// are told apart by their label.
+func (c *Linode) placementGroupLabelPrefix() string {
+	sum := sha256.Sum256([]byte(c.id))
+
+	return "garm-" + hex.EncodeToString(sum[:])[:8] + "-"
+}
+
+// placementGroupLabel returns the label of the managed placement group of a
+// pool in a region.
+func (c *Linode) placementGroupLabel(poolID, region string) string {
+	sum := sha256.Sum256([]byte(poolID))
+
+	return c.placementGroupLabelPrefix() + hex.EncodeToString(sum[:])[:16] + "-" + region
+}
+
+// ensureManagedPlacementGroup returns the anti-affinity placement group of
+// the pool in the region, creating it if it does not exist yet.
+func (c *Linode) ensureManagedPlacementGroup(ctx context.Context, poolID, region, policy string) (*linodego.PlacementGroup, error) {
+	label := c.placementGroupLabel(poolID, region)
+
+	groups, err := c.listPlacementGroups(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) > 0 {
+		return &groups[0], nil
+	}
+
+	if policy == "" {
+		policy = config.PlacementGroupPolicyStrict
+	}
+
+	group, err := c.api.CreatePlacementGroup(ctx, linodego.PlacementGroupCreateOptions{
+		Label:                label,
+		Region:               region,
+		PlacementGroupType:   linodego.PlacementGroupTypeAntiAffinityLocal,
+		PlacementGroupPolicy: linodego.PlacementGroupPolicy(policy),
+	})
+	if err != nil {
+		// Another provider invocation may have created it in the meantime.
+		if groups, lErr := c.listPlacementGroups(ctx, label); lErr == nil && len(groups) > 0 {
+			return &groups[0], nil
+		}
+
+		return nil, fmt.Errorf("creating placement group from Linode API: %w", err)
+	}
+
+	return group, nil
+}
+
+// listPlacementGroups returns the placement groups labelled label.
+func (c *Linode) listPlacementGroups(ctx context.Context, label string) ([]linodego.PlacementGroup, error) {
+	opts, err := labelIs(label).listOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := c.api.ListPlacementGroups(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing placement groups from Linode API: %w", err)
+	}
+
+	return slices.DeleteFunc(groups, func(g linodego.PlacementGroup) bool {
+		return g.Label != label
+	}), nil
+}
+
+// releasePlacementGroup deletes the managed placement group of a deleted
+// instance once it was the last one of its pool in the group.
+func (c *Linode) releasePlacementGroup(ctx context.Context, instance *linodego.Instance) error {
+	if instance.PlacementGroup == nil || !strings.HasPrefix(instance.PlacementGroup.Label, c.placementGroupLabelPrefix()) {
+		return nil
+	}
+
+	return c.deleteUnusedPlacementGroup(ctx, instance.PlacementGroup.ID, instance.ID)
+}
+
+// releaseAbandonedPlacementGroups deletes the managed placement groups of
+// the regions an instance failed to be created in, unless other runners
+// are members. Its errors are only logged, the groups are deleted along
+// with a later runner or the controller instances otherwise.
+func (c *Linode) releaseAbandonedPlacementGroups(ctx context.Context, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+
+	ctx, cancel := rollbackContext(ctx)
+	defer cancel()
+
+	for _, id := range ids {
+		if err := c.deleteUnusedPlacementGroup(ctx, id, 0); err != nil {
+			slog.WarnContext(ctx, "releasing abandoned placement group", "placement_group", id, "error", err)
+		}
+	}
+}
+
+// deleteUnusedPlacementGroup deletes a managed placement group without any
+// member other than the instance, which may still be listed once deleted.
+func (c *Linode) deleteUnusedPlacementGroup(ctx context.Context, id, instanceID int) error {
+	group, err := c.api.GetPlacementGroup(ctx, id)
+	if err != nil {
+		if linodego.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("getting placement group from Linode API: %w", err)
+	}
+
+	if slices.ContainsFunc(group.Members, func(m linodego.PlacementGroupMember) bool {
+		return m.LinodeID != instanceID
+	}) {
+		return nil
+	}
+
+	// A group which got a new member in the meantime cannot be deleted,
+	// it is left to the new runner.
+	if err := c.api.DeletePlacementGroup(ctx, group.ID); err != nil && !linodego.ErrHasStatus(err, http.StatusNotFound, http.StatusBadRequest) {
+		return fmt.Errorf("deleting placement group %d from Linode API: %w", group.ID, err)
+	}
+
+	return nil
+}
+
+// removeManagedPlacementGroups deletes the placement groups managed by this
+// controller.
+func (c *Linode) removeManagedPlacementGroups(ctx context.Context) error {
+	groups, err := c.api.ListPlacementGroups(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("listing placement groups from Linode API: %w", err)
+	}
+
+	var errs []error
+	for _, group := range groups {
+		if !strings.HasPrefix(group.Label, c.placementGroupLabelPrefix()) {
+			continue
+		}
+
+		if err := c.api.DeletePlacementGroup(ctx, group.ID); err != nil && !linodego.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("deleting placement group %d from Linode API: %w", group.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
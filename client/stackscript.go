@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/linode/linodego"
+)
+
+// stackScriptAnyImage is listed in the images of the StackScripts which can
+// be deployed with any image.
+const stackScriptAnyImage = "any/all"
+
+// stackScriptGARMFields are the user defined fields of a StackScript which
+// are set from the GARM bootstrap data, when the StackScript declares them.
+var stackScriptGARMFields = map[string]func(params.BootstrapInstance) string{
+	"garm_runner_name":    func(b params.BootstrapInstance) string { return b.Name },
+	"garm_pool_id":        func(b params.BootstrapInstance) string { return b.PoolID },
+	"garm_repo_url":       func(b params.BootstrapInstance) string { return b.RepoURL },
+	"garm_labels":         func(b params.BootstrapInstance) string { return strings.Join(b.Labels, ",") },
+	"garm_callback_url":   func(b params.BootstrapInstance) string { return b.CallbackURL },
+	"garm_metadata_url":   func(b params.BootstrapInstance) string { return b.MetadataURL },
+	"garm_instance_token": func(b params.BootstrapInstance) string { return b.InstanceToken },
+}
+
+// getStackScript returns the StackScript the runners are deployed with,
+// checking it supports the image.
+func (c *Linode) getStackScript(ctx context.Context, id int, image string) (*linodego.Stackscript, error) {
+	script, err := c.api.GetStackscript(ctx, id)
+	if err != nil {
+		if linodego.IsNotFound(err) {
+			return nil, fmt.Errorf("StackScript %d does not exist", id)
+		}
+
+		return nil, fmt.Errorf("getting StackScript from Linode API: %w", err)
+	}
+
+	// StackScripts only list public images.
+	if strings.HasPrefix(image, publicImagePrefix) && !slices.Contains(script.Images, stackScriptAnyImage) && !slices.Contains(script.Images, image) {
+		return nil, fmt.Errorf("StackScript %d does not support image %s", id, image)
+	}
+
+	return script, nil
+}
+
+// stackScriptData returns the user defined fields of the StackScript, the
+// pool data merged with the GARM bootstrap data. The fields set from the
+// bootstrap data cannot be set by the pool, and every field without default
+// needs a value.
+func stackScriptData(script *linodego.Stackscript, data map[string]string, bootstrapParams params.BootstrapInstance) (map[string]string, error) {
+	var udfs []linodego.StackscriptUDF
+	if script.UserDefinedFields != nil {
+		udfs = *script.UserDefinedFields
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(data)) {
+		if _, ok := stackScriptGARMFields[name]; ok {
+			return nil, fmt.Errorf("stackscript_data: %s is set by the provider", name)
+		}
+
+		if !slices.ContainsFunc(udfs, func(udf linodego.StackscriptUDF) bool { return udf.Name == name }) {
+			return nil, fmt.Errorf("stackscript_data: %s is not a field of StackScript %d", name, script.ID)
+		}
+	}
+
+	merged := maps.Clone(data)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+
+	for _, udf := range udfs {
+		if field, ok := stackScriptGARMFields[udf.Name]; ok {
+			merged[udf.Name] = field(bootstrapParams)
+			continue
+		}
+
+		if _, ok := merged[udf.Name]; !ok && udf.Default == "" {
+			return nil, fmt.Errorf("stackscript_data: %s is required by StackScript %d", udf.Name, script.ID)
+		}
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cloudbase/garm-provider-common/cloudconfig"
+	"github.com/cloudbase/garm-provider-common/defaults"
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/cloudbase/garm-provider-common/util"
+	"github.com/coreos/ignition/v2/config/v3_4/types"
+)
+
+const (
+	// cloudInitPreInstallDir holds the pre-install scripts until they are
+	// run, like with cloudconfig.GetCloudInitConfig.
+	cloudInitPreInstallDir = "/garm-pre-install"
+	// userDataMaxSize is the maximum size of the base64 encoded user data
+	// accepted by the Linode metadata service.
+	userDataMaxSize = 64 * 1024
+)
+
+// errUserDataTooLarge is returned when the user data does not fit in the
+// Linode metadata service, even compressed.
+var errUserDataTooLarge = errors.New("user data too large")
+
+// getUserData returns the base64 encoded instance user data, either a
+// cloud-init or an Ignition config depending on the image. The
+// CloudConfigSpec fields are only read from the parsed extra specs, never
+// from bootstrapParams.ExtraSpecs, so that both formats honour them alike.
+func getUserData(bootstrapParams params.BootstrapInstance, tools params.RunnerApplicationDownload, spec extraSpecs) (string, error) {
+	installScript, err := getRunnerInstallScript(bootstrapParams, tools, spec)
+	if err != nil {
+		return "", fmt.Errorf("generating install script: %w", err)
+	}
+
+	if spec.userDataFormat(bootstrapParams.Image) == userDataIgnition {
+		cfg, err := getIgnitionConfig(bootstrapParams, installScript, spec)
+		if err != nil {
+			return "", err
+		}
+
+		return encodeIgnitionConfig(cfg)
+	}
+
+	cfg, err := getCloudInitConfig(bootstrapParams, installScript, spec)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeCloudInitConfig(cfg)
+}
+
+// encodeCloudInitConfig returns the base64 encoded cloud-init config, gzip
+// compressed when it does not fit in the metadata service as is. cloud-init
+// decompresses the user data itself.
+func encodeCloudInitConfig(cfg string) (string, error) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(cfg))
+	if len(encoded) <= userDataMaxSize {
+		return encoded, nil
+	}
+
+	compressed, err := util.CompressData([]byte(cfg))
+	if err != nil {
+		return "", fmt.Errorf("compressing cloud-init config: %w", err)
+	}
+
+	return checkUserDataSize(len(encoded), base64.StdEncoding.EncodeToString(compressed))
+}
+
+// encodeIgnitionConfig returns the base64 encoded Ignition config, its files
+// being gzip compressed when it does not fit in the metadata service as is.
+// Unlike cloud-init, Ignition does not decompress the user data itself.
+func encodeIgnitionConfig(cfg types.Config) (string, error) {
+	out, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("marshalling ignition config: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(out)
+	if len(encoded) <= userDataMaxSize {
+		return encoded, nil
+	}
+
+	if err := compressIgnitionFiles(&cfg); err != nil {
+		return "", fmt.Errorf("compressing ignition config: %w", err)
+	}
+
+	out, err = json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("marshalling ignition config: %w", err)
+	}
+
+	return checkUserDataSize(len(encoded), base64.StdEncoding.EncodeToString(out))
+}
+
+// checkUserDataSize returns the compressed user data if it fits in the
+// metadata service, size being the size of the uncompressed one.
+func checkUserDataSize(size int, compressed string) (string, error) {
+	if len(compressed) > userDataMaxSize {
+		return "", fmt.Errorf("%w: %d bytes base64 encoded, %d bytes once compressed, over the %d bytes limit of the Linode metadata service, pre_install_scripts and runner_install_template have to be shortened",
+			errUserDataTooLarge, size, len(compressed), userDataMaxSize)
+	}
+
+	return compressed, nil
+}
+
+// getRunnerInstallScript renders the runner install script, from the
+// runner_install_template of the pool when set, with its extra_context.
+func getRunnerInstallScript(bootstrapParams params.BootstrapInstance, tools params.RunnerApplicationDownload, spec extraSpecs) ([]byte, error) {
+	if tools.GetFilename() == "" {
+		return nil, fmt.Errorf("missing tools filename")
+	}
+
+	if tools.GetDownloadURL() == "" {
+		return nil, fmt.Errorf("missing tools download URL")
+	}
+
+	installParams := cloudconfig.InstallRunnerParams{
+		FileName:          tools.GetFilename(),
+		DownloadURL:       tools.GetDownloadURL(),
+		TempDownloadToken: tools.GetTempDownloadToken(),
+		MetadataURL:       bootstrapParams.MetadataURL,
+		RunnerUsername:    defaults.DefaultUser,
+		RunnerGroup:       defaults.DefaultUser,
+		RepoURL:           bootstrapParams.RepoURL,
+		RunnerName:        bootstrapParams.Name,
+		RunnerLabels:      strings.Join(bootstrapParams.Labels, ","),
+		CallbackURL:       bootstrapParams.CallbackURL,
+		CallbackToken:     bootstrapParams.InstanceToken,
+		GitHubRunnerGroup: bootstrapParams.GitHubRunnerGroup,
+		ExtraContext:      spec.ExtraContext,
+		EnableBootDebug:   bootstrapParams.UserDataOptions.EnableBootDebug,
+		UseJITConfig:      bootstrapParams.JitConfigEnabled,
+	}
+
+	if len(bootstrapParams.CACertBundle) > 0 {
+		installParams.CABundle = string(bootstrapParams.CACertBundle)
+	}
+
+	script, err := cloudconfig.InstallRunnerScript(installParams, bootstrapParams.OSType, string(spec.RunnerInstallTemplate))
+	if err != nil {
+		return nil, fmt.Errorf("rendering runner install script: %w", err)
+	}
+
+	return script, nil
+}
+
+// getCloudInitConfig returns a cloud-init config setting up the runner, with
+// the same content as the one of cloudconfig.GetCloudInitConfig preceded by
+// the volume mount scripts.
+func getCloudInitConfig(bootstrapParams params.BootstrapInstance, installScript []byte, spec extraSpecs) (string, error) {
+	if bootstrapParams.OSType != params.Linux {
+		return "", fmt.Errorf("cloud-init is not supported on %s", bootstrapParams.OSType)
+	}
+
+	cfg := cloudconfig.NewDefaultCloudInitConfig()
+	if bootstrapParams.UserDataOptions.DisableUpdatesOnBoot {
+		cfg.PackageUpgrade = false
+		cfg.Packages = []string{}
+	}
+	cfg.AddPackage(bootstrapParams.UserDataOptions.ExtraPackages...)
+	cfg.AddPackage(spec.ExtraPackages...)
+
+	scripts, err := cloudInitMountScripts(bootstrapParams, spec)
+	if err != nil {
+		return "", err
+	}
+	addCloudInitMounts(cfg, scripts)
+
+	for _, name := range spec.preInstallScriptNames() {
+		p := path.Join(cloudInitPreInstallDir, name)
+		cfg.AddFile(spec.PreInstallScripts[name], p, "root:root", "755")
+		cfg.AddRunCmd(p)
+	}
+	cfg.AddRunCmd("rm -rf " + cloudInitPreInstallDir)
+
+	cfg.AddSSHKey(bootstrapParams.SSHKeys...)
+	cfg.AddFile(installScript, "/install_runner.sh", "root:root", "755")
+	cfg.AddRunCmd(fmt.Sprintf("su -l -c /install_runner.sh %s", defaults.DefaultUser))
+	cfg.AddRunCmd("rm -f /install_runner.sh")
+
+	if len(bootstrapParams.CACertBundle) > 0 {
+		if err := cfg.AddCACert(bootstrapParams.CACertBundle); err != nil {
+			return "", fmt.Errorf("adding CA cert bundle: %w", err)
+		}
+	}
+
+	userData, err := cfg.Serialize()
+	if err != nil {
+		return "", fmt.Errorf("serializing cloud-init config: %w", err)
+	}
+
+	return userData, nil
+}
+
+// cloudInitMountScripts returns the scripts mounting the volumes and the
+// scratch disk of the runner.
+func cloudInitMountScripts(bootstrapParams params.BootstrapInstance, spec extraSpecs) ([]mountScript, error) {
+	var scripts []mountScript
+	if spec.Volume != nil {
+		script, err := volumeMountScript(volumeDevice(bootstrapParams.Name), spec.Volume, false)
+		if err != nil {
+			return nil, err
+		}
+
+		scripts = append(scripts, mountScript{name: "mount-volume.sh", contents: script})
+	}
+
+	if spec.Disks != nil && spec.Disks.Scratch != nil {
+		script, err := volumeMountScript(scratchDiskDevice, spec.Disks.Scratch.volumeSpec(), false)
+		if err != nil {
+			return nil, err
+		}
+
+		scripts = append(scripts, mountScript{name: "mount-scratch-disk.sh", contents: script})
+	}
+
+	if spec.CacheVolume != nil {
+		script, err := cacheVolumeMountScript(bootstrapParams.PoolID, spec.CacheVolume)
+		if err != nil {
+			return nil, err
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
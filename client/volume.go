@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cloudbase/garm-provider-common/cloudconfig"
+	"github.com/linode/linodego"
+)
+
+const (
+	// volumeDevicePrefix prefixes the label of a volume in the path of its
+	// device on the instance it is attached to.
+	volumeDevicePrefix = "/dev/disk/by-id/scsi-0Linode_Volume_"
+	// volumeLabelMaxLength is the maximum length of a volume label.
+	volumeLabelMaxLength = 32
+	// volumeMinSize and volumeMaxSize bound the size of a volume, in GiB.
+	volumeMinSize = 10
+	volumeMaxSize = 10240
+
+	defaultVolumeFilesystem = "ext4"
+
+	// volumeDetachTimeout is how long a volume is waited for to be detached
+	// from a deleted instance before being deleted.
+	volumeDetachTimeout = 2 * time.Minute
+)
+
+var (
+	volumeFilesystems = []string{"ext4", "xfs", "btrfs"}
+
+	// volumeMountPathRegexp restricts the mount paths to the characters
+	// which need no quoting, neither in shell scripts nor in fstab.
+	volumeMountPathRegexp = regexp.MustCompile(`^(/[a-zA-Z0-9_.-]+)+$`)
+	volumeLabelRegexp     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+)
+
+// volumeSpec describes the Block Storage volume created along with a runner
+// and deleted with it.
+type volumeSpec struct {
+	// Size of the volume in GiB.
+	Size int `json:"size" jsonschema:"minimum=10,maximum=10240,description=Size of the volume in GiB."`
+	// Filesystem the volume is formatted with.
+	Filesystem string `json:"filesystem,omitempty" jsonschema:"enum=ext4,enum=xfs,enum=btrfs,description=Filesystem the volume is formatted with (default: ext4)."`
+	// MountPath is where the volume is mounted on the runner.
+	MountPath string `json:"mount_path" jsonschema:"pattern=^(/[a-zA-Z0-9_.-]+)+$,description=Absolute path where the volume is mounted on the runner."`
+}
+
+// validate checks the volume spec.
+func (v *volumeSpec) validate() error {
+	if v.Size < volumeMinSize || v.Size > volumeMaxSize {
+		return fmt.Errorf("volume size must be between %d and %d GiB", volumeMinSize, volumeMaxSize)
+	}
+
+	if v.Filesystem != "" && !slices.Contains(volumeFilesystems, v.Filesystem) {
+		return fmt.Errorf("volume filesystem must be one of %s", strings.Join(volumeFilesystems, ", "))
+	}
+
+	if !volumeMountPathRegexp.MatchString(v.MountPath) || path.Clean(v.MountPath) != v.MountPath {
+		return fmt.Errorf("volume mount path %q must be a clean absolute path", v.MountPath)
+	}
+
+	return nil
+}
+
+// filesystem returns the filesystem the volume is formatted with.
+func (v *volumeSpec) filesystem() string {
+	if v.Filesystem == "" {
+		return defaultVolumeFilesystem
+	}
+
+	return v.Filesystem
+}
+
+// volumeLabel returns the label of the volume of a runner. The runner name
+// is used as is when it is a valid volume label, it is hashed otherwise.
+func volumeLabel(name string) string {
+	if len(name) <= volumeLabelMaxLength && volumeLabelRegexp.MatchString(name) {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	label := "garm-" + hex.EncodeToString(sum[:])
+
+	return label[:volumeLabelMaxLength]
+}
+
+// volumeDevice returns the path of the device of the volume of a runner.
+func volumeDevice(name string) string {
+	return volumeDevicePrefix + volumeLabel(name)
+}
+
+// createVolume creates the volume of an instance and attaches it. The
+// instance must not be booted yet for the volume to be set up at boot.
+func (c *Linode) createVolume(ctx context.Context, instance *linodego.Instance, spec *volumeSpec, tags []string) (*linodego.Volume, error) {
+	volume, err := c.api.CreateVolume(ctx, linodego.VolumeCreateOptions{
+		Label:    volumeLabel(instance.Label),
+		LinodeID: instance.ID,
+		Size:     spec.Size,
+		Tags:     tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating volume from Linode API: %w", err)
+	}
+
+	return volume, nil
+}
+
+// listInstanceVolumes returns the volumes of this controller attached to
+// the instance.
+func (c *Linode) listInstanceVolumes(ctx context.Context, id int) ([]linodego.Volume, error) {
+	volumes, err := c.api.ListInstanceVolumes(ctx, id, nil)
+	if err != nil {
+		if linodego.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("listing instance volumes from Linode API: %w", err)
+	}
+
+	return slices.DeleteFunc(volumes, func(v linodego.Volume) bool {
+		return !hasTags(v.Tags, c.controllerTag())
+	}), nil
+}
+
+// listVolumes returns the volumes of this controller.
+func (c *Linode) listVolumes(ctx context.Context) ([]linodego.Volume, error) {
+	opts, err := hasTag(c.controllerTag()).listOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := c.api.ListVolumes(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing volumes from Linode API: %w", err)
+	}
+
+	return slices.DeleteFunc(volumes, func(v linodego.Volume) bool {
+		return !hasTags(v.Tags, c.controllerTag())
+	}), nil
+}
+
+// deleteVolumes deletes the volumes once they are detached from their
+// instance, which was deleted.
+func (c *Linode) deleteVolumes(ctx context.Context, volumes []linodego.Volume) error {
+	var errs []error
+	for _, volume := range volumes {
+		if err := c.deleteVolume(ctx, volume.ID); err != nil {
+			errs = append(errs, fmt.Errorf("deleting volume %d: %w", volume.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteVolume waits for a volume to be detached from its deleted instance,
+// then deletes it. A volume which no longer exists is considered deleted.
+func (c *Linode) deleteVolume(ctx context.Context, id int) error {
+	volume, err := c.waitForVolumeDetached(ctx, id)
+	if err != nil || volume == nil {
+		return err
+	}
+
+	if err := c.api.DeleteVolume(ctx, id); err != nil && !linodego.IsNotFound(err) {
+		return fmt.Errorf("deleting volume from Linode API: %w", err)
+	}
+
+	return nil
+}
+
+// releaseVolumes releases the volumes of a deleted instance: the cache
+// volumes are kept for the next runners, the other ones are deleted.
+func (c *Linode) releaseVolumes(ctx context.Context, volumes []linodego.Volume) error {
+	var errs []error
+	for _, volume := range volumes {
+		if !hasTags(volume.Tags, TagCache) {
+			if err := c.deleteVolume(ctx, volume.ID); err != nil {
+				errs = append(errs, fmt.Errorf("deleting volume %d: %w", volume.ID, err))
+			}
+
+			continue
+		}
+
+		if err := c.releaseCacheVolume(ctx, volume.ID); err != nil {
+			errs = append(errs, fmt.Errorf("releasing cache volume %d: %w", volume.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// waitForVolumeDetached polls the volume until it is detached from its
+// deleted instance. It returns nil if the volume no longer exists.
+func (c *Linode) waitForVolumeDetached(ctx context.Context, id int) (*linodego.Volume, error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, volumeDetachTimeout, fmt.Errorf("time limit of %s exceeded", volumeDetachTimeout))
+	defer cancel()
+
+	ticker := time.NewTicker(time.Duration(c.getWait(extraSpecs{}).PollInterval))
+	defer ticker.Stop()
+
+	for {
+		volume, err := c.api.GetVolume(ctx, id)
+		if err != nil {
+			if linodego.IsNotFound(err) {
+				return nil, nil
+			}
+
+			if ctx.Err() == nil {
+				return nil, fmt.Errorf("getting volume from Linode API: %w", err)
+			}
+		}
+
+		if err == nil && volume.LinodeID == nil {
+			return volume, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for the volume to be detached: %w", context.Cause(ctx))
+		case <-ticker.C:
+		}
+	}
+}
+
+var volumeMountScriptTemplate = template.Must(template.New("mount").Parse(`#!/bin/sh
+set -e
+
+# The volume is attached before the instance boots, its device may still
+# show up late.
+for i in $(seq 60); do
+	for device in {{ .Device }}; do
+		[ -b "$device" ] && break 2
+	done
+	sleep 1
+done
+
+mkdir -p {{ .MountPath }}
+{{- if .Optional }}
+
+if [ ! -b "$device" ]; then
+	echo "no volume to mount on {{ .MountPath }}" >&2
+	exit 0
+fi
+{{- end }}
+
+if ! blkid "$device" >/dev/null 2>&1; then
+	mkfs -t {{ .Filesystem }} "$device"
+fi
+
+echo "$device {{ .MountPath }} {{ .Filesystem }} defaults,nofail 0 2" >>/etc/fstab
+mount {{ .MountPath }}
+`))
+
+// volumeMountScript returns the script formatting a volume, unless it
+// already holds a filesystem, and mounting it. device may be a pattern
+// matching the devices of several volumes, the first attached one being
+// mounted. An optional volume which is not attached is skipped.
+func volumeMountScript(device string, spec *volumeSpec, optional bool) ([]byte, error) {
+	var script bytes.Buffer
+	err := volumeMountScriptTemplate.Execute(&script, struct {
+		Device     string
+		Filesystem string
+		MountPath  string
+		Optional   bool
+	}{
+		Device:     device,
+		Filesystem: spec.filesystem(),
+		MountPath:  spec.MountPath,
+		Optional:   optional,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rendering volume mount script: %w", err)
+	}
+
+	return script.Bytes(), nil
+}
+
+// mountScript is a script mounting a volume, run before anything else on
+// the runner so that the pre-install scripts and the runner can use it.
+type mountScript struct {
+	name     string
+	contents []byte
+}
+
+// addCloudInitMounts adds the mount scripts to a cloud-init config, before
+// any other command.
+func addCloudInitMounts(cfg *cloudconfig.CloudInit, scripts []mountScript) {
+	for _, script := range scripts {
+		p := fmt.Sprintf("/garm-%s", script.name)
+		cfg.AddFile(script.contents, p, "root:root", "755")
+		cfg.AddRunCmd(p)
+		cfg.AddRunCmd("rm -f " + p)
+	}
+}
+
+// mountUnitName returns the name of the systemd mount unit of a path, see
+// systemd-escape(1).
+func mountUnitName(p string) string {
+	var name strings.Builder
+	for i, r := range strings.Trim(p, "/") {
+		switch {
+		case r == '/':
+			name.WriteByte('-')
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.' && i > 0:
+			name.WriteRune(r)
+		default:
+			fmt.Fprintf(&name, `\x%02x`, r)
+		}
+	}
+
+	return name.String() + ".mount"
+}
@@ -3,16 +3,154 @@
 package config
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/invopop/jsonschema"
+)
+
+// tokenCommandTimeout bounds the execution of token_command.
+const tokenCommandTimeout = 30 * time.Second
+
+const (
+	// WaitUntilRunning waits for the runners to be booted and running.
+	WaitUntilRunning = "running"
+	// WaitUntilProvisioning returns as soon as Linode accepted the runners.
+	WaitUntilProvisioning = "provisioning"
+
+	// PlacementGroupPolicyStrict and PlacementGroupPolicyFlexible are the
+	// policies of the managed placement groups.
+	PlacementGroupPolicyStrict   = "strict"
+	PlacementGroupPolicyFlexible = "flexible"
+
+	// PlacementGroupFallbackUngrouped creates the runners outside of their
+	// placement group when it is full.
+	PlacementGroupFallbackUngrouped = "ungrouped"
+	// PlacementGroupFallbackFail fails to create the runners when their
+	// placement group is full.
+	PlacementGroupFallbackFail = "fail"
 )
 
 type Config struct {
 	// Region where to deploy things
-	Region string `toml:"region,omitempty"`
+	Region string `toml:"region,omitempty" jsonschema:"description=Region where to deploy the runners (default: us-ord)."`
+	// Regions is an ordered list of regions, the next one being tried
+	// when Linode lacks capacity or the VPC is in another region. It
+	// takes precedence over Region.
+	Regions []string `toml:"regions,omitempty" jsonschema:"minLength=1,uniqueItems=true,description=Ordered list of regions where to deploy the runners. The next region is tried when Linode lacks capacity or the VPC is in another region (takes precedence over region)."`
 	// Token used to authenticate the Linode HTTP client.
-	Token string `toml:"token"`
+	Token string `toml:"token,omitempty" jsonschema:"description=Token used to authenticate against the Linode API."`
+	// TokenFile is the path of a file holding the token. It is read on
+	// every invocation so a rotated token is picked up.
+	TokenFile string `toml:"token_file,omitempty" jsonschema:"description=Path of a file holding the token."`
+	// TokenEnv is the name of an environment variable holding the token.
+	TokenEnv string `toml:"token_env,omitempty" jsonschema:"description=Name of an environment variable holding the token."`
+	// TokenCommand is a command, and its arguments, printing the token.
+	TokenCommand []string `toml:"token_command,omitempty" jsonschema:"description=Command and its arguments printing the token on its standard output."`
+	// APIURL is the base URL of the Linode API.
+	APIURL string `toml:"api_url,omitempty" jsonschema:"description=Base URL of the Linode API (default: https://api.linode.com)."`
+	// APIMaxAttempts is the number of attempts of a Linode API request
+	// rejected because of rate limits or transient errors.
+	APIMaxAttempts int `toml:"api_max_attempts,omitempty" jsonschema:"description=Number of attempts of a Linode API request rejected because of rate limits or transient errors (default: 5)."`
+	// FirewallID is the Cloud Firewall assigned to the runners.
+	FirewallID int `toml:"firewall_id,omitempty" jsonschema:"description=ID of the Cloud Firewall assigned to the runners."`
+	// ManagedFirewall makes the provider create and manage a firewall
+	// dropping all inbound traffic, when FirewallID is not set.
+	ManagedFirewall bool `toml:"managed_firewall,omitempty" jsonschema:"description=Create and manage a firewall dropping all inbound traffic to the runners."`
+	// DeleteConcurrency is the number of instances deleted at once when
+	// removing all the instances of the controller.
+	DeleteConcurrency int `toml:"delete_concurrency,omitempty" jsonschema:"description=Number of instances deleted at once when removing all the instances of the controller (default: 10)."`
+	// Network defines the network interfaces of the runners,
+	// they only get a public interface when it is not set.
+	Network *Network `toml:"network,omitempty" jsonschema:"description=Network interfaces of the runners."`
+	// Wait defines how the runners are waited for once created.
+	Wait *Wait `toml:"wait,omitempty" jsonschema:"description=How the runners are waited for once created."`
+	// PlacementGroup puts the runners into a placement group.
+	PlacementGroup *PlacementGroup `toml:"placement_group,omitempty" jsonschema:"description=Placement group of the runners."`
+}
+
+// PlacementGroup defines the placement group the runners are put into,
+// either an existing one or an anti-affinity group managed per pool and
+// region.
+type PlacementGroup struct {
+	// ID of an existing placement group, a group is managed per pool and
+	// region when it is not set.
+	ID int `toml:"id,omitempty" json:"id,omitempty" jsonschema:"minimum=1,description=ID of an existing placement group (default: an anti-affinity group managed per pool and region)."`
+	// Policy of the managed placement groups, strict or flexible.
+	Policy string `toml:"policy,omitempty" json:"policy,omitempty" jsonschema:"enum=strict,enum=flexible,description=Policy of the managed placement groups (default: strict)."`
+	// Fallback is what happens when the placement group is full or
+	// cannot host the runner: it is created ungrouped or fails.
+	Fallback string `toml:"fallback,omitempty" json:"fallback,omitempty" jsonschema:"enum=ungrouped,enum=fail,description=Create the runners outside of the placement group or fail when it is full (default: ungrouped)."`
+}
+
+// Wait defines how the runners are waited for once created, unset fields
+// falling back to the defaults.
+type Wait struct {
+	// Timeout is how long to wait for the runners.
+	Timeout Duration `toml:"timeout,omitempty" json:"timeout,omitempty" jsonschema:"description=How long to wait for the runners (default: 5m)."`
+	// PollInterval is the delay between two checks of the runner status.
+	PollInterval Duration `toml:"poll_interval,omitempty" json:"poll_interval,omitempty" jsonschema:"description=Delay between two checks of the runner status (default: 5s)."`
+	// Until is the status to wait for, running or provisioning.
+	Until string `toml:"until,omitempty" json:"until,omitempty" jsonschema:"enum=running,enum=provisioning,description=Wait for the runners to be running or return as soon as Linode is provisioning them (default: running)."`
+}
+
+// Duration is a time.Duration written as a string, like "5m" or "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// JSONSchema describes a duration as a string. JSON schema minimums only
+// apply to numbers, the pattern has no sign to reject negative durations.
+func (Duration) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type:    "string",
+		Pattern: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+	}
+}
+
+// Network defines the network interfaces attached to the runners.
+type Network struct {
+	// VPC is the ID or the label of the VPC to attach the runners to.
+	VPC string `toml:"vpc,omitempty" json:"vpc,omitempty" jsonschema:"description=ID or label of the VPC to attach the runners to."`
+	// Subnet is the ID or the label of the VPC subnet.
+	Subnet string `toml:"subnet,omitempty" json:"subnet,omitempty" jsonschema:"description=ID or label of the VPC subnet."`
+	// NAT1To1 gives the VPC interface a public IPv4 address.
+	NAT1To1 bool `toml:"nat_1_1,omitempty" json:"nat_1_1,omitempty" jsonschema:"description=Map a public IPv4 address to the VPC address (1:1 NAT)."`
+	// Public adds a public interface alongside the VPC interface. A public
+	// interface is always added when no VPC is set.
+	Public bool `toml:"public,omitempty" json:"public,omitempty" jsonschema:"description=Add a public interface alongside the VPC interface."`
+	// VLANs to attach the runners to.
+	VLANs []VLAN `toml:"vlans,omitempty" json:"vlans,omitempty" jsonschema:"description=VLANs to attach the runners to."`
+}
+
+// VLAN defines a VLAN interface.
+type VLAN struct {
+	// Label of the VLAN, it is created if it does not exist.
+	Label string `toml:"label" json:"label" jsonschema:"description=Label of the VLAN."`
+	// IPAMAddress is only kept to reject it: every runner of the pool would
+	// be given the same static address on the VLAN.
+	IPAMAddress string `toml:"ipam_address,omitempty" json:"ipam_address,omitempty" jsonschema:"-"`
 }
 
 // New returns a new config
@@ -33,10 +171,215 @@ func New(cfgFile string) (*Config, error) {
 	return &config, nil
 }
 
+// GetRegions returns the ordered list of regions to deploy the runners to.
+func (c *Config) GetRegions() []string {
+	if len(c.Regions) > 0 {
+		return c.Regions
+	}
+
+	return []string{c.Region}
+}
+
+// GetToken returns the Linode API token from its configured source, one of
+// token, token_file, token_env and token_command. token_command is killed
+// when ctx is done. Errors never contain the token itself.
+func (c *Config) GetToken(ctx context.Context) (string, error) {
+	var token string
+	switch {
+	case c.Token != "":
+		token = c.Token
+	case c.TokenFile != "":
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading token_file: %w", err)
+		}
+
+		token = string(data)
+	case c.TokenEnv != "":
+		v, ok := os.LookupEnv(c.TokenEnv)
+		if !ok {
+			return "", fmt.Errorf("token_env: environment variable %s is not set", c.TokenEnv)
+		}
+
+		token = v
+	case len(c.TokenCommand) > 0:
+		ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+		defer cancel()
+
+		// The output is deliberately left out of the errors.
+		out, err := exec.CommandContext(ctx, c.TokenCommand[0], c.TokenCommand[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("running token_command: %w", err)
+		}
+
+		token = string(out)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("token is empty")
+	}
+
+	return token, nil
+}
+
 func (c *Config) Validate() error {
-	if c.Token == "" {
-		return fmt.Errorf("token needs to be set")
+	sources := 0
+	for _, set := range []bool{c.Token != "", c.TokenFile != "", c.TokenEnv != "", len(c.TokenCommand) > 0} {
+		if set {
+			sources++
+		}
+	}
+
+	if sources == 0 {
+		return fmt.Errorf("token needs to be set (token, token_file, token_env or token_command)")
+	}
+
+	if sources > 1 {
+		return fmt.Errorf("token, token_file, token_env and token_command are mutually exclusive")
+	}
+
+	if len(c.TokenCommand) > 0 && c.TokenCommand[0] == "" {
+		return fmt.Errorf("token_command needs a command")
+	}
+
+	if c.APIURL != "" {
+		if u, err := url.Parse(c.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("api_url needs to be an absolute URL")
+		}
+	}
+
+	if c.APIMaxAttempts < 0 {
+		return fmt.Errorf("api_max_attempts needs to be a positive integer")
+	}
+
+	if c.DeleteConcurrency < 0 {
+		return fmt.Errorf("delete_concurrency needs to be a positive integer")
+	}
+
+	if err := ValidateRegions(c.Regions); err != nil {
+		return err
+	}
+
+	if c.FirewallID < 0 {
+		return fmt.Errorf("firewall_id needs to be a positive integer")
+	}
+
+	if c.FirewallID != 0 && c.ManagedFirewall {
+		return fmt.Errorf("firewall_id and managed_firewall are mutually exclusive")
+	}
+
+	if c.Network != nil {
+		if err := c.Network.Validate(); err != nil {
+			return fmt.Errorf("validating network: %w", err)
+		}
+	}
+
+	if c.Wait != nil {
+		if err := c.Wait.Validate(); err != nil {
+			return fmt.Errorf("validating wait: %w", err)
+		}
+	}
+
+	if c.PlacementGroup != nil {
+		if err := c.PlacementGroup.Validate(); err != nil {
+			return fmt.Errorf("validating placement_group: %w", err)
+		}
 	}
 
 	return nil
 }
+
+func (w *Wait) Validate() error {
+	if w.Timeout < 0 {
+		return fmt.Errorf("timeout needs to be positive")
+	}
+
+	if w.PollInterval < 0 {
+		return fmt.Errorf("poll_interval needs to be positive")
+	}
+
+	if w.Until != "" && w.Until != WaitUntilRunning && w.Until != WaitUntilProvisioning {
+		return fmt.Errorf("until needs to be %s or %s", WaitUntilRunning, WaitUntilProvisioning)
+	}
+
+	return nil
+}
+
+func (p *PlacementGroup) Validate() error {
+	if p.ID < 0 {
+		return fmt.Errorf("id needs to be a positive integer")
+	}
+
+	if p.Policy != "" && p.Policy != PlacementGroupPolicyStrict && p.Policy != PlacementGroupPolicyFlexible {
+		return fmt.Errorf("policy needs to be %s or %s", PlacementGroupPolicyStrict, PlacementGroupPolicyFlexible)
+	}
+
+	if p.ID != 0 && p.Policy != "" {
+		return fmt.Errorf("policy only applies to managed placement groups, not to id")
+	}
+
+	if p.Fallback != "" && p.Fallback != PlacementGroupFallbackUngrouped && p.Fallback != PlacementGroupFallbackFail {
+		return fmt.Errorf("fallback needs to be %s or %s", PlacementGroupFallbackUngrouped, PlacementGroupFallbackFail)
+	}
+
+	return nil
+}
+
+// ValidateRegions checks an ordered list of regions, each region being tried
+// at most once.
+func ValidateRegions(regions []string) error {
+	for i, region := range regions {
+		if region == "" {
+			return fmt.Errorf("regions cannot contain an empty region")
+		}
+
+		if slices.Contains(regions[:i], region) {
+			return fmt.Errorf("region %s is listed more than once", region)
+		}
+	}
+
+	return nil
+}
+
+func (n *Network) Validate() error {
+	if n.VPC == "" && n.Subnet != "" {
+		return fmt.Errorf("subnet needs a vpc")
+	}
+
+	if n.VPC != "" && n.Subnet == "" {
+		return fmt.Errorf("vpc needs a subnet")
+	}
+
+	if n.VPC == "" && n.NAT1To1 {
+		return fmt.Errorf("nat_1_1 needs a vpc")
+	}
+
+	for _, vlan := range n.VLANs {
+		if vlan.Label == "" {
+			return fmt.Errorf("vlan label needs to be set")
+		}
+
+		if vlan.IPAMAddress != "" {
+			return fmt.Errorf("ipam_address for vlan %s is not supported, every runner would get the same address", vlan.Label)
+		}
+	}
+
+	return nil
+}
+
+// JSONSchema returns the JSON schema of the provider configuration.
+func JSONSchema() (string, error) {
+	reflector := jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		DoNotReference:            true,
+		FieldNameTag:              "toml",
+	}
+
+	schema, err := json.MarshalIndent(reflector.Reflect(&Config{}), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshalling schema: %w", err)
+	}
+
+	return string(schema), nil
+}
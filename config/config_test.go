@@ -3,9 +3,15 @@
 package config_test
 
 import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/flatcar/garm-provider-linode/config"
 )
@@ -28,6 +34,242 @@ func TestCredentials_Validate(t *testing.T) {
 			config:  &config.Config{},
 			wantErr: true,
 		},
+		{
+			name: "valid (token_file)",
+			config: &config.Config{
+				TokenFile: "/run/secrets/linode-token",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid (token_env)",
+			config: &config.Config{
+				TokenEnv: "LINODE_TOKEN",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid (token_command)",
+			config: &config.Config{
+				TokenCommand: []string{"vault", "kv", "get", "-field=token", "secret/linode"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid (token and token_file)",
+			config: &config.Config{
+				Token:     "foo",
+				TokenFile: "/run/secrets/linode-token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid (token_env and token_command)",
+			config: &config.Config{
+				TokenEnv:     "LINODE_TOKEN",
+				TokenCommand: []string{"vault", "kv", "get", "-field=token", "secret/linode"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid (empty token_command)",
+			config: &config.Config{
+				TokenCommand: []string{""},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid API settings",
+			config: &config.Config{
+				Token:          "foo",
+				APIURL:         "https://api.linode.com",
+				APIMaxAttempts: 10,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid api_url",
+			config: &config.Config{
+				Token:  "foo",
+				APIURL: "api.linode.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid api_max_attempts",
+			config: &config.Config{
+				Token:          "foo",
+				APIMaxAttempts: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid delete_concurrency",
+			config: &config.Config{
+				Token:             "foo",
+				DeleteConcurrency: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid regions",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", "us-sea"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid regions (empty region)",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", ""},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid regions (duplicate region)",
+			config: &config.Config{
+				Token:   "foo",
+				Regions: []string{"us-ord", "us-sea", "us-ord"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid (firewall_id and managed_firewall)",
+			config: &config.Config{
+				Token:           "foo",
+				FirewallID:      1234,
+				ManagedFirewall: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid network",
+			config: &config.Config{
+				Token: "foo",
+				Network: &config.Network{
+					VPC:     "runners",
+					Subnet:  "ci",
+					NAT1To1: true,
+					VLANs: []config.VLAN{
+						{
+							Label: "builds",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid network (missing subnet)",
+			config: &config.Config{
+				Token: "foo",
+				Network: &config.Network{
+					VPC: "runners",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid network (NAT without VPC)",
+			config: &config.Config{
+				Token: "foo",
+				Network: &config.Network{
+					NAT1To1: true,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid network (VLAN address shared by the runners)",
+			config: &config.Config{
+				Token: "foo",
+				Network: &config.Network{
+					VLANs: []config.VLAN{
+						{
+							Label:       "builds",
+							IPAMAddress: "10.0.0.1/24",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid wait",
+			config: &config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					Timeout:      config.Duration(10 * time.Minute),
+					PollInterval: config.Duration(time.Second),
+					Until:        config.WaitUntilProvisioning,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid wait (negative timeout)",
+			config: &config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					Timeout: config.Duration(-time.Minute),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid wait (unknown status)",
+			config: &config.Config{
+				Token: "foo",
+				Wait: &config.Wait{
+					Until: "booted",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid managed placement group",
+			config: &config.Config{
+				Token: "foo",
+				PlacementGroup: &config.PlacementGroup{
+					Policy:   config.PlacementGroupPolicyFlexible,
+					Fallback: config.PlacementGroupFallbackFail,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid existing placement group",
+			config: &config.Config{
+				Token: "foo",
+				PlacementGroup: &config.PlacementGroup{
+					ID: 1234,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid placement group (policy with id)",
+			config: &config.Config{
+				Token: "foo",
+				PlacementGroup: &config.PlacementGroup{
+					ID:     1234,
+					Policy: config.PlacementGroupPolicyStrict,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid placement group (unknown fallback)",
+			config: &config.Config{
+				Token: "foo",
+				PlacementGroup: &config.PlacementGroup{
+					Fallback: "retry",
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -40,3 +282,158 @@ func TestCredentials_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestNew(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`
+token = "foo"
+
+[wait]
+  timeout = "10m"
+  poll_interval = "2s"
+  until = "provisioning"
+`), 0o600))
+
+	cfg, err := config.New(cfgFile)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Region, "us-ord")
+	assert.Equal(t, cfg.Wait, &config.Wait{
+		Timeout:      config.Duration(10 * time.Minute),
+		PollInterval: config.Duration(2 * time.Second),
+		Until:        config.WaitUntilProvisioning,
+	})
+
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`
+token = "foo"
+
+[wait]
+  timeout = "ten minutes"
+`), 0o600))
+
+	_, err = config.New(cfgFile)
+	require.ErrorContains(t, err, "decoding config")
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := config.JSONSchema()
+	require.NoError(t, err)
+
+	var s struct {
+		Properties map[string]any `json:"properties"`
+		Required   []string       `json:"required"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(schema), &s))
+
+	assert.Contains(t, s.Properties, "region")
+	assert.Contains(t, s.Properties, "token")
+	assert.Contains(t, s.Properties, "token_file")
+	assert.Contains(t, s.Properties, "token_env")
+	assert.Contains(t, s.Properties, "token_command")
+	assert.Empty(t, s.Required)
+}
+
+func TestGetToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("from-file\n"), 0o600))
+
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(emptyFile, []byte("\n"), 0o600))
+
+	t.Setenv("GARM_LINODE_TEST_TOKEN", "from-env")
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		config  *config.Config
+		want    string
+		wantErr string
+	}{
+		{
+			name: "token",
+			config: &config.Config{
+				Token: "from-config",
+			},
+			want: "from-config",
+		},
+		{
+			name: "token_file",
+			config: &config.Config{
+				TokenFile: tokenFile,
+			},
+			want: "from-file",
+		},
+		{
+			name: "token_env",
+			config: &config.Config{
+				TokenEnv: "GARM_LINODE_TEST_TOKEN",
+			},
+			want: "from-env",
+		},
+		{
+			name: "token_command",
+			config: &config.Config{
+				TokenCommand: []string{"echo", "from-command"},
+			},
+			want: "from-command",
+		},
+		{
+			name: "missing token_file",
+			config: &config.Config{
+				TokenFile: filepath.Join(t.TempDir(), "missing"),
+			},
+			wantErr: "reading token_file",
+		},
+		{
+			name: "empty token_file",
+			config: &config.Config{
+				TokenFile: emptyFile,
+			},
+			wantErr: "token is empty",
+		},
+		{
+			name: "missing token_env",
+			config: &config.Config{
+				TokenEnv: "GARM_LINODE_TEST_MISSING",
+			},
+			wantErr: "token_env: environment variable GARM_LINODE_TEST_MISSING is not set",
+		},
+		{
+			name: "failing token_command",
+			config: &config.Config{
+				TokenCommand: []string{"sh", "-c", "echo secret-token; exit 1"},
+			},
+			wantErr: "running token_command: exit status 1",
+		},
+		{
+			name: "canceled token_command",
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			}(),
+			config: &config.Config{
+				TokenCommand: []string{"sleep", "10"},
+			},
+			wantErr: "running token_command: context canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = t.Context()
+			}
+
+			token, err := tt.config.GetToken(ctx)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				assert.NotContains(t, err.Error(), "secret-token")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, token, tt.want)
+		})
+	}
+}
@@ -31,7 +31,7 @@ func main() {
 		log.Fatal(err)
 	}
 
-	prov, err := provider.New(executionEnv.ProviderConfigFile, executionEnv.ControllerID)
+	prov, err := provider.New(ctx, executionEnv.ProviderConfigFile, executionEnv.ControllerID)
 	if err != nil {
 		log.Fatal(err)
 	}
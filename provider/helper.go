@@ -3,10 +3,14 @@
 package provider
 
 import (
+	"net"
 	"strconv"
+	"strings"
 
 	"github.com/cloudbase/garm-provider-common/params"
 	"github.com/linode/linodego"
+
+	"github.com/flatcar/garm-provider-linode/client"
 )
 
 var (
@@ -27,8 +31,10 @@ var (
 )
 
 // instanceLinodeToGarm takes care of converting a Linode instance
-// to a Garm instance.
-func instanceLinodeToGarm(in *linodego.Instance) params.ProviderInstance {
+// to a Garm instance. ips is optional and carries the addresses which are
+// not part of the instance itself, like the VPC ones. fault summarizes the
+// failed events of the instance.
+func instanceLinodeToGarm(in *linodego.Instance, ips *linodego.InstanceIPAddressResponse, os client.InstanceOS, fault string) params.ProviderInstance {
 	if in == nil {
 		return params.ProviderInstance{}
 	}
@@ -43,19 +49,106 @@ func instanceLinodeToGarm(in *linodego.Instance) params.ProviderInstance {
 		ProviderID: strconv.Itoa(in.ID),
 		Name:       in.Label,
 		Status:     instanceStatus,
-		// TODO: Add OSType, OSName, OSVersion and OSArch.
+		OSType:     os.Type,
+		OSName:     os.Name,
+		OSVersion:  os.Version,
+		OSArch:     os.Arch,
+	}
+
+	if fault != "" {
+		out.ProviderFault = []byte(fault)
 	}
 
-	// Best effort to get the public IP.
-	ipv4s := in.IPv4
-	if len(ipv4s) > 0 {
-		out.Addresses = []params.Address{
-			{
-				Type:    params.PublicAddress,
-				Address: ipv4s[0].String(),
-			},
+	addresses := addressSet{}
+
+	for _, ip := range in.IPv4 {
+		if ip == nil {
+			continue
 		}
+
+		addresses.add(ip.String(), !ip.IsPrivate())
 	}
 
+	// The SLAAC address comes with its prefix length, like 2600:3c00::1/128.
+	if in.IPv6 != "" {
+		address, _, _ := strings.Cut(in.IPv6, "/")
+		addresses.add(address, true)
+	}
+
+	if ips != nil && ips.IPv4 != nil {
+		for _, list := range [][]*linodego.InstanceIP{ips.IPv4.Public, ips.IPv4.Private, ips.IPv4.Shared, ips.IPv4.Reserved} {
+			for _, ip := range list {
+				if ip == nil {
+					continue
+				}
+
+				addresses.add(ip.Address, ip.Public && !isPrivate(ip.Address))
+			}
+		}
+
+		for _, ip := range ips.IPv4.VPC {
+			if ip == nil {
+				continue
+			}
+
+			if ip.Address != nil {
+				addresses.add(*ip.Address, false)
+			}
+
+			if ip.NAT1To1 != nil {
+				addresses.add(*ip.NAT1To1, true)
+			}
+		}
+	}
+
+	if ips != nil && ips.IPv6 != nil {
+		if ips.IPv6.SLAAC != nil {
+			addresses.add(ips.IPv6.SLAAC.Address, true)
+		}
+
+		for _, ip := range ips.IPv6.VPC {
+			public := ip.IPv6IsPublic != nil && *ip.IPv6IsPublic
+			for _, address := range ip.IPv6Addresses {
+				addresses.add(address.SLAACAddress, public)
+			}
+		}
+	}
+
+	out.Addresses = addresses.list
+
 	return out
 }
+
+// addressSet collects the addresses of an instance, as they are reported
+// both by the instance and by its IP addresses.
+type addressSet struct {
+	list []params.Address
+	seen map[string]bool
+}
+
+func (s *addressSet) add(address string, public bool) {
+	if address == "" || s.seen[address] {
+		return
+	}
+
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	s.seen[address] = true
+
+	addressType := params.PrivateAddress
+	if public {
+		addressType = params.PublicAddress
+	}
+
+	s.list = append(s.list, params.Address{
+		Type:    addressType,
+		Address: address,
+	})
+}
+
+// isPrivate returns true for the private ranges, like 192.168.0.0/16.
+func isPrivate(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.IsPrivate()
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/linode/linodego"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/garm-provider-linode/client"
+)
+
+func TestInstanceLinodeToGarm(t *testing.T) {
+	os := client.InstanceOS{
+		Type:    params.Linux,
+		Name:    "ubuntu",
+		Version: "24.04",
+		Arch:    params.Amd64,
+	}
+
+	tests := []struct {
+		name     string
+		instance *linodego.Instance
+		ips      *linodego.InstanceIPAddressResponse
+		fault    string
+		want     params.ProviderInstance
+	}{
+		{
+			name: "No instance",
+			want: params.ProviderInstance{},
+		},
+		{
+			name: "Unknown status",
+			instance: &linodego.Instance{
+				ID:     1234,
+				Label:  "test-instance",
+				Status: "unknown",
+			},
+			want: params.ProviderInstance{
+				ProviderID: "1234",
+				Name:       "test-instance",
+				Status:     params.InstanceStatusUnknown,
+				OSType:     params.Linux,
+				OSName:     "ubuntu",
+				OSVersion:  "24.04",
+				OSArch:     params.Amd64,
+			},
+		},
+		{
+			name: "Fault",
+			instance: &linodego.Instance{
+				ID:     1234,
+				Label:  "test-instance",
+				Status: linodego.InstanceOffline,
+			},
+			fault: "linode_boot failed",
+			want: params.ProviderInstance{
+				ProviderID:    "1234",
+				Name:          "test-instance",
+				Status:        params.InstanceStopped,
+				OSType:        params.Linux,
+				OSName:        "ubuntu",
+				OSVersion:     "24.04",
+				OSArch:        params.Amd64,
+				ProviderFault: []byte("linode_boot failed"),
+			},
+		},
+		{
+			name: "Instance addresses",
+			instance: &linodego.Instance{
+				ID:     1234,
+				Label:  "test-instance",
+				Status: linodego.InstanceRunning,
+				IPv4: []*net.IP{
+					ptr(net.ParseIP("172.105.1.2")),
+					nil,
+					ptr(net.ParseIP("192.168.130.4")),
+				},
+				IPv6: "2600:3c00::f03c:93ff:fe1a:2b3c/128",
+			},
+			want: params.ProviderInstance{
+				ProviderID: "1234",
+				Name:       "test-instance",
+				Status:     params.InstanceRunning,
+				OSType:     params.Linux,
+				OSName:     "ubuntu",
+				OSVersion:  "24.04",
+				OSArch:     params.Amd64,
+				Addresses: []params.Address{
+					{Type: params.PublicAddress, Address: "172.105.1.2"},
+					{Type: params.PrivateAddress, Address: "192.168.130.4"},
+					{Type: params.PublicAddress, Address: "2600:3c00::f03c:93ff:fe1a:2b3c"},
+				},
+			},
+		},
+		{
+			name: "IP addresses",
+			instance: &linodego.Instance{
+				ID:     1234,
+				Label:  "test-instance",
+				Status: linodego.InstanceRunning,
+				IPv4: []*net.IP{
+					ptr(net.ParseIP("172.105.1.2")),
+				},
+				IPv6: "2600:3c00::f03c:93ff:fe1a:2b3c/128",
+			},
+			ips: &linodego.InstanceIPAddressResponse{
+				IPv4: &linodego.InstanceIPv4Response{
+					Public: []*linodego.InstanceIP{
+						{Address: "172.105.1.2", Public: true},
+					},
+					Private: []*linodego.InstanceIP{
+						{Address: "192.168.130.4"},
+					},
+					Shared: []*linodego.InstanceIP{
+						{Address: "172.105.1.3", Public: true},
+						nil,
+					},
+					Reserved: []*linodego.InstanceIP{
+						{Address: "172.105.1.4", Public: true},
+					},
+					VPC: []*linodego.VPCIP{
+						{Address: ptr("10.0.0.2"), NAT1To1: ptr("172.105.1.5")},
+						{Address: ptr("10.0.1.2")},
+						nil,
+					},
+				},
+				IPv6: &linodego.InstanceIPv6Response{
+					LinkLocal: &linodego.InstanceIP{Address: "fe80::f03c:93ff:fe1a:2b3c"},
+					SLAAC:     &linodego.InstanceIP{Address: "2600:3c00::f03c:93ff:fe1a:2b3c"},
+					Global: []linodego.IPv6Range{
+						{Range: "2600:3c00:e000:1::", Prefix: 64},
+					},
+					VPC: []linodego.VPCIP{
+						{
+							IPv6IsPublic:  ptr(true),
+							IPv6Addresses: []linodego.VPCIPIPv6Address{{SLAACAddress: "2600:3c00:e000:2::1"}},
+						},
+						{
+							IPv6Addresses: []linodego.VPCIPIPv6Address{{SLAACAddress: "fd00:e000:3::1"}},
+						},
+					},
+				},
+			},
+			want: params.ProviderInstance{
+				ProviderID: "1234",
+				Name:       "test-instance",
+				Status:     params.InstanceRunning,
+				OSType:     params.Linux,
+				OSName:     "ubuntu",
+				OSVersion:  "24.04",
+				OSArch:     params.Amd64,
+				Addresses: []params.Address{
+					{Type: params.PublicAddress, Address: "172.105.1.2"},
+					{Type: params.PublicAddress, Address: "2600:3c00::f03c:93ff:fe1a:2b3c"},
+					{Type: params.PrivateAddress, Address: "192.168.130.4"},
+					{Type: params.PublicAddress, Address: "172.105.1.3"},
+					{Type: params.PublicAddress, Address: "172.105.1.4"},
+					{Type: params.PrivateAddress, Address: "10.0.0.2"},
+					{Type: params.PublicAddress, Address: "172.105.1.5"},
+					{Type: params.PrivateAddress, Address: "10.0.1.2"},
+					{Type: params.PublicAddress, Address: "2600:3c00:e000:2::1"},
+					{Type: params.PrivateAddress, Address: "fd00:e000:3::1"},
+				},
+			},
+		},
+		{
+			name: "Public IP address in a private range",
+			instance: &linodego.Instance{
+				ID:     1234,
+				Label:  "test-instance",
+				Status: linodego.InstanceRunning,
+			},
+			ips: &linodego.InstanceIPAddressResponse{
+				IPv4: &linodego.InstanceIPv4Response{
+					Public: []*linodego.InstanceIP{
+						{Address: "10.0.0.2", Public: true},
+					},
+				},
+			},
+			want: params.ProviderInstance{
+				ProviderID: "1234",
+				Name:       "test-instance",
+				Status:     params.InstanceRunning,
+				OSType:     params.Linux,
+				OSName:     "ubuntu",
+				OSVersion:  "24.04",
+				OSArch:     params.Amd64,
+				Addresses: []params.Address{
+					{Type: params.PrivateAddress, Address: "10.0.0.2"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, instanceLinodeToGarm(tt.instance, tt.ips, os, tt.fault), tt.want)
+		})
+	}
+}
+
+func TestAddressSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []params.Address
+		want      []params.Address
+	}{
+		{
+			name: "Empty address",
+			addresses: []params.Address{
+				{Type: params.PublicAddress, Address: ""},
+			},
+		},
+		{
+			name: "Duplicated addresses",
+			addresses: []params.Address{
+				{Type: params.PublicAddress, Address: "172.105.1.2"},
+				{Type: params.PrivateAddress, Address: "192.168.130.4"},
+				{Type: params.PrivateAddress, Address: "172.105.1.2"},
+				{Type: params.PrivateAddress, Address: "192.168.130.4"},
+			},
+			want: []params.Address{
+				{Type: params.PublicAddress, Address: "172.105.1.2"},
+				{Type: params.PrivateAddress, Address: "192.168.130.4"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := addressSet{}
+			for _, address := range tt.addresses {
+				s.add(address.Address, address.Type == params.PublicAddress)
+			}
+
+			assert.Equal(t, s.list, tt.want)
+		})
+	}
+}
+
+func TestIsPrivate(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{address: "10.0.0.2", want: true},
+		{address: "172.16.0.2", want: true},
+		{address: "192.168.130.4", want: true},
+		{address: "172.105.1.2", want: false},
+		{address: "fd00:e000:3::1", want: true},
+		{address: "2600:3c00::f03c:93ff:fe1a:2b3c", want: false},
+		{address: "fe80::f03c:93ff:fe1a:2b3c", want: false},
+		{address: "2600:3c00::f03c:93ff:fe1a:2b3c/128", want: false},
+		{address: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			assert.Equal(t, isPrivate(tt.address), tt.want)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
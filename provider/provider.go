@@ -4,19 +4,24 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 
 	"github.com/flatcar/garm-provider-linode/client"
 	"github.com/flatcar/garm-provider-linode/client/api"
 	"github.com/flatcar/garm-provider-linode/config"
 
-	execution "github.com/cloudbase/garm-provider-common/execution/v0.1.0"
+	"github.com/cloudbase/garm-provider-common/execution/common"
+	executionv010 "github.com/cloudbase/garm-provider-common/execution/v0.1.0"
+	executionv011 "github.com/cloudbase/garm-provider-common/execution/v0.1.1"
 	"github.com/cloudbase/garm-provider-common/params"
 )
 
 var (
-	_       execution.ExternalProvider = &linodeProvider{}
-	Version                            = "v0.0.0-unknown"
+	_       executionv010.ExternalProvider = &linodeProvider{}
+	_       executionv011.ExternalProvider = &linodeProvider{}
+	Version                                = "v0.0.0-unknown"
 )
 
 type linodeProvider struct {
@@ -25,13 +30,13 @@ type linodeProvider struct {
 	controllerID string
 }
 
-func New(configPath, controllerID string) (execution.ExternalProvider, error) {
+func New(ctx context.Context, configPath, controllerID string) (executionv011.ExternalProvider, error) {
 	conf, err := config.New(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
 
-	a, err := api.New(conf)
+	a, err := api.New(ctx, conf)
 	if err != nil {
 		return nil, fmt.Errorf("creating API client: %w", err)
 	}
@@ -55,7 +60,28 @@ func (p *linodeProvider) CreateInstance(ctx context.Context, bootstrapParams par
 		return params.ProviderInstance{}, fmt.Errorf("creating the instance: %w", err)
 	}
 
-	inst := instanceLinodeToGarm(instance)
+	// The instance exists, GARM has to learn about it even when the
+	// lookups below fail, or nothing would ever delete it.
+	ips, err := p.cli.GetInstanceIPAddresses(ctx, instance.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "getting instance IP addresses", "instance", instance.ID, "error", err)
+	}
+
+	os, err := p.cli.GetInstanceOS(ctx, instance)
+	if err != nil {
+		slog.WarnContext(ctx, "getting instance OS", "instance", instance.ID, "error", err)
+	}
+
+	// GARM knows better what the runner is built for.
+	if bootstrapParams.OSType != "" {
+		os.Type = bootstrapParams.OSType
+	}
+
+	if bootstrapParams.OSArch != "" {
+		os.Arch = bootstrapParams.OSArch
+	}
+
+	inst := instanceLinodeToGarm(instance, ips, os, "")
 
 	return inst, nil
 }
@@ -76,7 +102,24 @@ func (p *linodeProvider) GetInstance(ctx context.Context, ID string) (params.Pro
 		return params.ProviderInstance{}, fmt.Errorf("getting instance: %w", err)
 	}
 
-	inst := instanceLinodeToGarm(instance)
+	ips, err := p.cli.GetInstanceIPAddresses(ctx, instance.ID)
+	if err != nil {
+		return params.ProviderInstance{}, fmt.Errorf("getting instance IP addresses: %w", err)
+	}
+
+	// The OS and the faults only complete what GARM knows about the
+	// instance, they are left out when they cannot be looked up.
+	os, err := p.cli.GetInstanceOS(ctx, instance)
+	if err != nil {
+		slog.WarnContext(ctx, "getting instance OS", "instance", instance.ID, "error", err)
+	}
+
+	faults, err := p.cli.GetInstanceFaults(ctx, instance.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "getting instance faults", "instance", instance.ID, "error", err)
+	}
+
+	inst := instanceLinodeToGarm(instance, ips, os, faults[instance.ID])
 
 	return inst, nil
 }
@@ -93,9 +136,35 @@ func (p *linodeProvider) ListInstances(ctx context.Context, poolID string) ([]pa
 		return nil, fmt.Errorf("listing instances: %w", err)
 	}
 
+	ids := make([]int, len(instances))
+	for i, instance := range instances {
+		ids[i] = instance.ID
+	}
+
+	// The faults of all the instances are looked up at once. Like the OS,
+	// they are left out when they cannot be looked up, rather than hiding
+	// the whole pool from GARM.
+	faults, err := p.cli.GetInstanceFaults(ctx, ids...)
+	if err != nil {
+		slog.WarnContext(ctx, "getting instance faults", "pool", poolID, "error", err)
+	}
+
+	// The VPC addresses are only listed per instance, the runners reached
+	// through a VPC having no other address. The images are cached, the OS
+	// only costs one API call per image.
 	res := make([]params.ProviderInstance, len(instances))
 	for i, instance := range instances {
-		res[i] = instanceLinodeToGarm(&instance)
+		ips, err := p.cli.GetInstanceIPAddresses(ctx, instance.ID)
+		if err != nil {
+			slog.WarnContext(ctx, "getting instance IP addresses", "instance", instance.ID, "error", err)
+		}
+
+		os, err := p.cli.GetInstanceOS(ctx, &instance)
+		if err != nil {
+			slog.WarnContext(ctx, "getting instance OS", "instance", instance.ID, "error", err)
+		}
+
+		res[i] = instanceLinodeToGarm(&instance, ips, os, faults[instance.ID])
 	}
 
 	return res, nil
@@ -112,11 +181,59 @@ func (p *linodeProvider) RemoveAllInstances(ctx context.Context) error {
 
 // Stop shuts down the instance.
 func (p *linodeProvider) Stop(ctx context.Context, instance string, force bool) error {
-	// TODO: Implements p.cli.StopInstance(ctx, instance, force).
+	if err := p.cli.StopInstance(ctx, instance, force); err != nil {
+		return fmt.Errorf("stopping instance: %w", err)
+	}
+
 	return nil
 }
 
 // Start boots up an instance.
 func (p *linodeProvider) Start(ctx context.Context, instance string) error {
+	if err := p.cli.StartInstance(ctx, instance); err != nil {
+		return fmt.Errorf("starting instance: %w", err)
+	}
+
 	return nil
 }
+
+// GetSupportedInterfaceVersions returns the interface versions implemented by the provider.
+func (p *linodeProvider) GetSupportedInterfaceVersions(ctx context.Context) []string {
+	return []string{common.Version010, common.Version011}
+}
+
+// ValidatePoolInfo validates the pool information before any runner is created.
+// providerConfig is the path of the provider configuration file, which has
+// already been loaded and validated by New. The v0.1.1 executor provides
+// neither the image nor the flavor, only the extra specs are validated then.
+func (p *linodeProvider) ValidatePoolInfo(ctx context.Context, image string, flavor string, providerConfig string, extraspecs string) error {
+	if err := client.ValidateExtraSpecs(json.RawMessage(extraspecs)); err != nil {
+		return fmt.Errorf("validating extra specs: %w", err)
+	}
+
+	if err := p.cli.ValidatePoolInfo(ctx, image, flavor, json.RawMessage(extraspecs)); err != nil {
+		return fmt.Errorf("validating pool info: %w", err)
+	}
+
+	return nil
+}
+
+// GetConfigJSONSchema returns the JSON schema of the provider configuration.
+func (p *linodeProvider) GetConfigJSONSchema(ctx context.Context) (string, error) {
+	schema, err := config.JSONSchema()
+	if err != nil {
+		return "", fmt.Errorf("getting config JSON schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// GetExtraSpecsJSONSchema returns the JSON schema of the pool extra specs.
+func (p *linodeProvider) GetExtraSpecsJSONSchema(ctx context.Context) (string, error) {
+	schema, err := client.ExtraSpecsJSONSchema()
+	if err != nil {
+		return "", fmt.Errorf("getting extra specs JSON schema: %w", err)
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,396 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudbase/garm-provider-common/execution/common"
+	executionv011 "github.com/cloudbase/garm-provider-common/execution/v0.1.1"
+	"github.com/cloudbase/garm-provider-common/params"
+	"github.com/linode/linodego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flatcar/garm-provider-linode/client"
+	"github.com/flatcar/garm-provider-linode/client/api"
+	"github.com/flatcar/garm-provider-linode/config"
+)
+
+// mockLinode only implements the Linode API calls of the provider tests,
+// the other ones panic on the nil embedded interface.
+type mockLinode struct {
+	api.LinodeAPI
+	createInstance         func(context.Context, linodego.InstanceCreateOptions) (*linodego.Instance, error)
+	deleteInstance         func(context.Context, int) error
+	getImage               func(context.Context, string) (*linodego.Image, error)
+	getInstance            func(context.Context, int) (*linodego.Instance, error)
+	getInstanceIPAddresses func(context.Context, int) (*linodego.InstanceIPAddressResponse, error)
+	listEvents             func(context.Context, *linodego.ListOptions) ([]linodego.Event, error)
+	listInstances          func(context.Context, *linodego.ListOptions) ([]linodego.Instance, error)
+}
+
+func (m *mockLinode) CreateInstance(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+	return m.createInstance(ctx, opts)
+}
+
+func (m *mockLinode) DeleteInstance(ctx context.Context, ID int) error {
+	return m.deleteInstance(ctx, ID)
+}
+
+func (m *mockLinode) GetImage(ctx context.Context, ID string) (*linodego.Image, error) {
+	return m.getImage(ctx, ID)
+}
+
+func (m *mockLinode) GetInstance(ctx context.Context, ID int) (*linodego.Instance, error) {
+	return m.getInstance(ctx, ID)
+}
+
+func (m *mockLinode) GetInstanceIPAddresses(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+	return m.getInstanceIPAddresses(ctx, ID)
+}
+
+func (m *mockLinode) ListEvents(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+	return m.listEvents(ctx, opts)
+}
+
+func (m *mockLinode) ListInstances(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+	return m.listInstances(ctx, opts)
+}
+
+func newProvider(t *testing.T, m *mockLinode) *linodeProvider {
+	t.Helper()
+
+	cfg := &config.Config{
+		Token: "foo",
+		Wait: &config.Wait{
+			Until: config.WaitUntilProvisioning,
+		},
+	}
+
+	cli, err := client.New(cfg, m, "1234")
+	require.NoError(t, err)
+
+	return &linodeProvider{
+		cfg:          cfg,
+		cli:          cli,
+		controllerID: "1234",
+	}
+}
+
+func TestCreateInstanceLookups(t *testing.T) {
+	instance := func(ctx context.Context, opts linodego.InstanceCreateOptions) (*linodego.Instance, error) {
+		return &linodego.Instance{
+			ID:     9876,
+			Label:  opts.Label,
+			Image:  opts.Image,
+			Status: linodego.InstanceProvisioning,
+			IPv4:   []*net.IP{ptr(net.ParseIP("172.105.1.2"))},
+		}, nil
+	}
+	ips := func(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+		return &linodego.InstanceIPAddressResponse{}, nil
+	}
+	image := func(ctx context.Context, ID string) (*linodego.Image, error) {
+		return &linodego.Image{ID: ID}, nil
+	}
+
+	tests := []struct {
+		name   string
+		ips    func(context.Context, int) (*linodego.InstanceIPAddressResponse, error)
+		image  func(context.Context, string) (*linodego.Image, error)
+		wantOS string
+	}{
+		{
+			name: "IP addresses lookup fails",
+			ips: func(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+				return nil, &linodego.Error{Code: 500}
+			},
+			image:  image,
+			wantOS: "ubuntu",
+		},
+		{
+			name:  "OS lookup fails",
+			ips:   ips,
+			image: func(ctx context.Context, ID string) (*linodego.Image, error) { return nil, &linodego.Error{Code: 500} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := false
+			p := newProvider(t, &mockLinode{
+				createInstance:         instance,
+				getInstanceIPAddresses: tt.ips,
+				getImage:               tt.image,
+				deleteInstance: func(ctx context.Context, ID int) error {
+					deleted = true
+					return nil
+				},
+			})
+
+			inst, err := p.CreateInstance(t.Context(), params.BootstrapInstance{
+				Name:   "test-instance",
+				OSArch: params.Amd64,
+				OSType: params.Linux,
+				Flavor: "g6-standard-2",
+				Image:  "linode/ubuntu24.04",
+				Tools: []params.RunnerApplicationDownload{
+					{
+						OS:           ptr("linux"),
+						Architecture: ptr("x64"),
+						DownloadURL:  ptr("http://test.com"),
+						Filename:     ptr("runner.tar.gz"),
+					},
+				},
+				PoolID: "test-pool",
+			})
+			require.NoError(t, err)
+			assert.False(t, deleted)
+
+			// GARM gets the instance, with what could be looked up.
+			assert.Equal(t, inst.ProviderID, "9876")
+			assert.Equal(t, inst.Name, "test-instance")
+			assert.Equal(t, inst.OSType, params.Linux)
+			assert.Equal(t, inst.OSArch, params.Amd64)
+			assert.Equal(t, inst.OSName, tt.wantOS)
+			assert.Equal(t, inst.Addresses, []params.Address{
+				{Type: params.PublicAddress, Address: "172.105.1.2"},
+			})
+		})
+	}
+}
+
+func TestGetInstanceLookups(t *testing.T) {
+	instance := linodego.Instance{
+		ID:     9876,
+		Label:  "test-instance",
+		Image:  "linode/ubuntu24.04",
+		Status: linodego.InstanceRunning,
+		IPv4:   []*net.IP{ptr(net.ParseIP("172.105.1.2"))},
+		Tags: []string{
+			client.TagPool + "=test-pool",
+			client.TagController + "=1234",
+		},
+	}
+	events := func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+		return []linodego.Event{
+			{
+				Action: linodego.ActionLinodeBoot,
+				Status: linodego.EventFailed,
+				Entity: &linodego.EventEntity{ID: float64(instance.ID), Type: linodego.EntityLinode},
+			},
+		}, nil
+	}
+	image := func(ctx context.Context, ID string) (*linodego.Image, error) {
+		return &linodego.Image{ID: ID}, nil
+	}
+	fail := &linodego.Error{Code: 500}
+
+	tests := []struct {
+		name      string
+		events    func(context.Context, *linodego.ListOptions) ([]linodego.Event, error)
+		image     func(context.Context, string) (*linodego.Image, error)
+		wantOS    string
+		wantFault bool
+	}{
+		{
+			name:      "Success",
+			events:    events,
+			image:     image,
+			wantOS:    "ubuntu",
+			wantFault: true,
+		},
+		{
+			name:   "Faults lookup fails",
+			events: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) { return nil, fail },
+			image:  image,
+			wantOS: "ubuntu",
+		},
+		{
+			name:      "OS lookup fails",
+			events:    events,
+			image:     func(ctx context.Context, ID string) (*linodego.Image, error) { return nil, fail },
+			wantFault: true,
+		},
+	}
+
+	for _, tt := range tests {
+		check := func(t *testing.T, inst params.ProviderInstance) {
+			t.Helper()
+
+			assert.Equal(t, inst.ProviderID, "9876")
+			assert.Equal(t, inst.Name, "test-instance")
+			assert.Equal(t, inst.Status, params.InstanceRunning)
+			assert.Equal(t, inst.OSType, params.Linux)
+			assert.Equal(t, inst.OSArch, params.Amd64)
+			assert.Equal(t, inst.OSName, tt.wantOS)
+			assert.Equal(t, len(inst.ProviderFault) > 0, tt.wantFault)
+		}
+
+		t.Run(tt.name+" getting the instance", func(t *testing.T) {
+			p := newProvider(t, &mockLinode{
+				getInstance: func(ctx context.Context, ID int) (*linodego.Instance, error) {
+					return &instance, nil
+				},
+				getInstanceIPAddresses: func(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+					return &linodego.InstanceIPAddressResponse{}, nil
+				},
+				getImage:   tt.image,
+				listEvents: tt.events,
+			})
+
+			inst, err := p.GetInstance(t.Context(), "9876")
+			require.NoError(t, err)
+			check(t, inst)
+		})
+
+		t.Run(tt.name+" listing the instances", func(t *testing.T) {
+			p := newProvider(t, &mockLinode{
+				listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+					return []linodego.Instance{instance}, nil
+				},
+				getInstanceIPAddresses: func(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+					return &linodego.InstanceIPAddressResponse{}, nil
+				},
+				getImage:   tt.image,
+				listEvents: tt.events,
+			})
+
+			insts, err := p.ListInstances(t.Context(), "test-pool")
+			require.NoError(t, err)
+			require.Len(t, insts, 1)
+			check(t, insts[0])
+		})
+	}
+}
+
+func TestListInstancesAddresses(t *testing.T) {
+	// The instance is only attached to a VPC, its address is not part of
+	// the instance itself.
+	instance := linodego.Instance{
+		ID:     9876,
+		Label:  "test-instance",
+		Image:  "linode/ubuntu24.04",
+		Status: linodego.InstanceRunning,
+		Tags: []string{
+			client.TagPool + "=test-pool",
+			client.TagController + "=1234",
+		},
+	}
+
+	tests := []struct {
+		name          string
+		ips           func(context.Context, int) (*linodego.InstanceIPAddressResponse, error)
+		wantAddresses []params.Address
+	}{
+		{
+			name: "VPC address",
+			ips: func(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+				return &linodego.InstanceIPAddressResponse{
+					IPv4: &linodego.InstanceIPv4Response{
+						VPC: []*linodego.VPCIP{
+							{Address: ptr("10.0.0.2")},
+						},
+					},
+				}, nil
+			},
+			wantAddresses: []params.Address{
+				{Type: params.PrivateAddress, Address: "10.0.0.2"},
+			},
+		},
+		{
+			name: "IP addresses lookup fails",
+			ips: func(ctx context.Context, ID int) (*linodego.InstanceIPAddressResponse, error) {
+				return nil, &linodego.Error{Code: 500}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newProvider(t, &mockLinode{
+				listInstances: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Instance, error) {
+					return []linodego.Instance{instance}, nil
+				},
+				getInstanceIPAddresses: tt.ips,
+				getImage: func(ctx context.Context, ID string) (*linodego.Image, error) {
+					return &linodego.Image{ID: ID}, nil
+				},
+				listEvents: func(ctx context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+					return nil, nil
+				},
+			})
+
+			insts, err := p.ListInstances(t.Context(), "test-pool")
+			require.NoError(t, err)
+			require.Len(t, insts, 1)
+			assert.Equal(t, insts[0].ProviderID, "9876")
+			assert.Equal(t, insts[0].Addresses, tt.wantAddresses)
+		})
+	}
+}
+
+func TestValidatePoolInfoExecution(t *testing.T) {
+	// The API is unreachable, validating the pool from GARM must not
+	// need it as neither the image nor the flavor are known.
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+token = "foo"
+api_url = "http://127.0.0.1:1"
+api_max_attempts = 1
+`), 0o600))
+
+	tests := []struct {
+		name       string
+		extraSpecs string
+		wantErr    string
+	}{
+		{
+			name: "No extra specs",
+		},
+		{
+			name:       "Valid extra specs",
+			extraSpecs: `{"disks": {"root_size": 20480}}`,
+		},
+		{
+			name:       "Invalid extra specs",
+			extraSpecs: `{"disks": {"root_size": -1}}`,
+			wantErr:    "failed to validate pool info: validating extra specs: invalid extra specs: disks.root_size: Must be greater than or equal to 1",
+		},
+		{
+			name:       "Extra specs failing the provider checks",
+			extraSpecs: `{"network": {"vpc": "runners"}}`,
+			wantErr:    "failed to validate pool info: validating pool info: getting extra specs: validating network: vpc needs a subnet",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GARM only sets the environment, nothing is written to stdin.
+			t.Setenv("GARM_COMMAND", string(common.ValidatePoolInfoCommand))
+			t.Setenv("GARM_CONTROLLER_ID", "1234")
+			t.Setenv("GARM_POOL_ID", "")
+			t.Setenv("GARM_INSTANCE_ID", "")
+			t.Setenv("GARM_PROVIDER_CONFIG_FILE", configFile)
+			t.Setenv("GARM_POOL_EXTRASPECS", tt.extraSpecs)
+
+			env, err := executionv011.GetEnvironment()
+			require.NoError(t, err)
+
+			p, err := New(t.Context(), env.ProviderConfigFile, env.ControllerID)
+			require.NoError(t, err)
+
+			_, err = env.Run(t.Context(), p)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}